@@ -0,0 +1,140 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrSearchLimit the search stopped at a limit, such as
+	// SolverOptions.MaxDiscrepancies, before it could tell whether there is
+	// a solution
+	ErrSearchLimit error = errors.New("search stopped at a limit without an answer")
+)
+
+// Assumption a temporary restriction on a variable for SolveWithAssumptions:
+// that it takes the given value or, if Exclude is set, that it doesn't
+type Assumption[T comparable] struct {
+	VariableName
+	Value   T
+	Exclude bool
+}
+
+// AssumeEquals assume that a variable takes the given value
+func AssumeEquals[T comparable](name VariableName, value T) Assumption[T] {
+	return Assumption[T]{VariableName: name, Value: value}
+}
+
+// AssumeNotEquals assume that a variable doesn't take the given value
+func AssumeNotEquals[T comparable](name VariableName, value T) Assumption[T] {
+	return Assumption[T]{VariableName: name, Value: value, Exclude: true}
+}
+
+// String describe the assumption, e.g. "A = 3" or "B != red"
+func (assumption Assumption[T]) String() string {
+	if assumption.Exclude {
+		return fmt.Sprintf("%v != %v", assumption.VariableName, assumption.Value)
+	}
+	return fmt.Sprintf("%v = %v", assumption.VariableName, assumption.Value)
+}
+
+// AssumptionResult outcome of SolveWithAssumptions
+type AssumptionResult[T comparable] struct {
+	// Solved whether there is a solution under the assumptions
+	Solved bool
+	// Solution the solution found, if Solved
+	Solution Variables[T]
+	// Failed if not Solved, a minimal set of the assumptions that has no
+	// solution: leaving out any one of them would allow one. Empty if the
+	// model has no solution even without assumptions.
+	Failed []Assumption[T]
+}
+
+// SolveWithAssumptions find out whether there is a solution when the given
+// assumptions also hold, without changing the model. The search runs on a
+// copy of State with the assumed values restricting the variables' domains,
+// so State is left as it was. If there is no solution, the assumptions
+// responsible are narrowed down with QuickXplain, which solves a number of
+// smaller problems with the same Options and can take much longer than the
+// first attempt. Learned nogoods are neither used nor kept, since they might
+// not hold without the assumptions. If the search stops at a limit without
+// an answer, ErrSearchLimit is returned, and if an assumption names a
+// variable that isn't in State, ErrUnknownVariable.
+func (solver *BackTrackingCSPSolver[T]) SolveWithAssumptions(ctx context.Context, assumptions ...Assumption[T]) (AssumptionResult[T], error) {
+	for _, assumption := range assumptions {
+		if solver.State.Vars.IndexOf(assumption.VariableName) < 0 {
+			return AssumptionResult[T]{}, ErrUnknownVariable
+		}
+	}
+	items := make([]int, len(assumptions))
+	for k := range items {
+		items[k] = k
+	}
+	// assume applies the assumptions at the given positions to a copy of the
+	// state, or returns false if they contradict the pre-assigned variables
+	assume := func(subset []int) (CSPState[T], bool) {
+		candidate := solver.State.Copy()
+		for _, k := range subset {
+			assumption := assumptions[k]
+			variable := candidate.Vars.Find(assumption.VariableName)
+			if !variable.Empty {
+				if (variable.Value == assumption.Value) == assumption.Exclude {
+					return candidate, false
+				}
+				continue
+			}
+			if assumption.Exclude {
+				variable.SetDomain(variable.Domain.Remove(assumption.Value))
+			} else if variable.Domain.Contains(assumption.Value) {
+				variable.SetDomain(Domain[T]{assumption.Value})
+			} else {
+				variable.SetDomain(Domain[T]{})
+			}
+		}
+		return candidate, true
+	}
+
+	result := AssumptionResult[T]{Failed: []Assumption[T]{}}
+	candidate, ok := assume(items)
+	if ok {
+		solved, err := candidate.satisfiable(ctx, solver.Options)
+		if err != nil {
+			return result, err
+		}
+		if solved {
+			result.Solved, result.Solution = true, candidate.Vars.Copy()
+			return result, nil
+		}
+	}
+
+	satisfiable := func(subset []int) (bool, error) {
+		candidate, ok := assume(subset)
+		if !ok {
+			return false, nil
+		}
+		return candidate.satisfiable(ctx, solver.Options)
+	}
+	conflict, err := quickXplain(nil, true, items, satisfiable)
+	if err != nil {
+		return result, err
+	}
+	for _, k := range conflict {
+		result.Failed = append(result.Failed, assumptions[k])
+	}
+	return result, nil
+}
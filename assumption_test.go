@@ -0,0 +1,99 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSolveWithAssumptions(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(1, 6)),
+		NewVariable("B", IntRange(1, 6)),
+		NewVariable("C", IntRange(1, 6)),
+		NewVariable("D", IntRange(1, 6)),
+	}
+	constraints := Constraints[int]{
+		LessThan[int]("A", "B"),
+		LessThan[int]("B", "C"),
+	}
+	vars[3].SetValue(4)
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	solver.Options.LearnNogoods = true
+
+	result, err := solver.SolveWithAssumptions(context.TODO(), AssumeEquals("A", 2), AssumeNotEquals("C", 3))
+	assert.Nil(t, err)
+	assert.True(t, result.Solved)
+	assert.Equal(t, []int{2, 3, 4, 4}, values(result.Solution))
+	assert.Empty(t, result.Failed)
+	// the model itself is untouched
+	assert.True(t, solver.State.Vars.Find("A").Empty)
+	assert.Equal(t, Domain[int]{1, 2, 3, 4, 5}, solver.State.Vars.Find("A").Domain)
+	assert.Empty(t, solver.Nogoods())
+
+	// B = 1 leaves nothing for A, whatever else is assumed
+	result, err = solver.SolveWithAssumptions(context.TODO(),
+		AssumeNotEquals("C", 2), AssumeEquals("B", 1), AssumeEquals("C", 4), AssumeNotEquals("A", 3))
+	assert.Nil(t, err)
+	assert.False(t, result.Solved)
+	assert.Equal(t, []Assumption[int]{AssumeEquals("B", 1)}, result.Failed)
+
+	// A = 3 and C != 5 only conflict together
+	result, err = solver.SolveWithAssumptions(context.TODO(),
+		AssumeEquals("A", 3), AssumeNotEquals("B", 1), AssumeNotEquals("C", 5))
+	assert.Nil(t, err)
+	assert.False(t, result.Solved)
+	assert.Equal(t, []Assumption[int]{AssumeEquals("A", 3), AssumeNotEquals("C", 5)}, result.Failed)
+	assert.Equal(t, "C != 5", result.Failed[1].String())
+
+	// assumptions about pre-assigned variables are checked against them
+	result, err = solver.SolveWithAssumptions(context.TODO(), AssumeEquals("A", 1), AssumeNotEquals("D", 4))
+	assert.Nil(t, err)
+	assert.False(t, result.Solved)
+	assert.Equal(t, []Assumption[int]{AssumeNotEquals("D", 4)}, result.Failed)
+
+	// assumptions about variables that don't exist are an error
+	result, err = solver.SolveWithAssumptions(context.TODO(), AssumeEquals("A", 1), AssumeEquals("Z", 1))
+	assert.ErrorIs(t, err, ErrUnknownVariable)
+	assert.False(t, result.Solved)
+
+	// with no solution at all, no assumption is to blame
+	solver.State.Constraints = append(solver.State.Constraints, LessThan[int]("C", "A"))
+	result, err = solver.SolveWithAssumptions(context.TODO(), AssumeEquals("A", 1))
+	assert.Nil(t, err)
+	assert.False(t, result.Solved)
+	assert.Empty(t, result.Failed)
+}
+
+func TestSolveWithAssumptionsLimit(t *testing.T) {
+	// with one discrepancy, Q0 = 0 isn't enough to find a solution, though
+	// there is one, so nothing can be blamed on the assumption
+	vars, constraints := queensProblem(8)
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	solver.Options.Search = LimitedDiscrepancySearch
+	solver.Options.MaxDiscrepancies = 1
+	result, err := solver.SolveWithAssumptions(context.TODO(), AssumeEquals("Q0", 0))
+	assert.ErrorIs(t, err, ErrSearchLimit)
+	assert.False(t, result.Solved)
+	assert.Empty(t, result.Failed)
+
+	solver.Options.Search = ChronologicalBacktracking
+	result, err = solver.SolveWithAssumptions(context.TODO(), AssumeEquals("Q0", 0))
+	assert.Nil(t, err)
+	assert.True(t, result.Solved)
+}
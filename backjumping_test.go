@@ -0,0 +1,135 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConflictDirectedBackjumping(t *testing.T) {
+	// A must equal C, but there are twelve unrelated variables assigned
+	// between them. Chronological backtracking tries every combination of
+	// the unrelated variables before it gets back to A.
+	vars := Variables[int]{NewVariable("A", IntRange(0, 2))}
+	for i := 0; i < 12; i++ {
+		vars = append(vars, NewVariable(VariableName(fmt.Sprintf("B%d", i)), IntRange(0, 2)))
+	}
+	vars = append(vars, NewVariable("C", Domain[int]{1}))
+	evaluations := 0
+	equals := Equals[int]("A", "C")
+	constraints := Constraints[int]{
+		Constraint[int]{Vars: equals.Vars, ConstraintFunction: func(variables *Variables[int]) bool {
+			evaluations++
+			return equals.ConstraintFunction(variables)
+		}},
+	}
+	solver := NewBackTrackingCSPSolver(vars.Copy(), constraints)
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.Greater(t, evaluations, 4096)
+
+	evaluations = 0
+	solver = NewBackTrackingCSPSolver(vars.Copy(), constraints)
+	solver.Options.Search = ConflictDirectedBackjumping
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.Less(t, evaluations, 10)
+	assert.Equal(t, 1, solver.State.Vars.Find("A").Value)
+	assert.Equal(t, 1, solver.State.Vars.Find("C").Value)
+	for i := 0; i < 12; i++ {
+		assert.Equal(t, 0, solver.State.Vars.Find(VariableName(fmt.Sprintf("B%d", i))).Value)
+	}
+}
+
+func TestBackjumpingWithPropagation(t *testing.T) {
+	// A = 0 empties the domain of C through propagation, with twelve
+	// unrelated variables assigned in between
+	vars := Variables[int]{NewVariable("A", IntRange(0, 2))}
+	for i := 0; i < 12; i++ {
+		vars = append(vars, NewVariable(VariableName(fmt.Sprintf("B%d", i)), IntRange(0, 2)))
+	}
+	vars = append(vars, NewVariable("C", Domain[int]{1}))
+	propagations := Propagations[int]{
+		Propagation[int]{Vars: VariableNames{"A"}, PropagationFunction: func(assignment VariableAssignment[int], variables *Variables[int]) []DomainRemoval[int] {
+			if assignment.Value == 0 {
+				return []DomainRemoval[int]{{VariableName: "C", Value: 1}}
+			}
+			return nil
+		}},
+	}
+	nodes := 0
+	counter := Constraint[int]{Vars: VariableNames{"B11"}, ConstraintFunction: func(variables *Variables[int]) bool {
+		nodes++
+		return true
+	}}
+	solver := NewBackTrackingCSPSolverWithPropagation(vars, Constraints[int]{counter}, propagations)
+	solver.Options.Search = ConflictDirectedBackjumping
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.Equal(t, 1, solver.State.Vars.Find("A").Value)
+	assert.Equal(t, Domain[int]{1}, solver.State.Vars.Find("C").Domain)
+	assert.Less(t, nodes, 10)
+}
+
+func TestBackjumpingMapColoring(t *testing.T) {
+	// backjumping finds the same solutions as chronological backtracking
+	colors := Domain[string]{"red", "green", "blue"}
+	vars := Variables[string]{
+		NewVariable("WA", colors),
+		NewVariable("NT", colors),
+		NewVariable("Q", colors),
+		NewVariable("NSW", colors),
+		NewVariable("V", colors),
+		NewVariable("SA", colors),
+		NewVariable("T", colors),
+	}
+	constraints := Constraints[string]{
+		NotEquals[string]("WA", "NT"),
+		NotEquals[string]("WA", "SA"),
+		NotEquals[string]("NT", "SA"),
+		NotEquals[string]("NT", "Q"),
+		NotEquals[string]("Q", "SA"),
+		NotEquals[string]("Q", "NSW"),
+		NotEquals[string]("NSW", "V"),
+		NotEquals[string]("NSW", "SA"),
+		NotEquals[string]("V", "SA"),
+	}
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	solver.Options.Search = ConflictDirectedBackjumping
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.True(t, solver.State.Constraints.AllSatisfied(&solver.State.Vars))
+	assert.Equal(t, "blue", solver.State.Vars.Find("SA").Value)
+
+	// an unsatisfiable problem is reported as such
+	vars = Variables[string]{
+		NewVariable("A", colors[:2]),
+		NewVariable("B", colors[:2]),
+		NewVariable("C", colors[:2]),
+	}
+	solver = NewBackTrackingCSPSolver(vars, AllUnique[string]("A", "B", "C"))
+	solver.Options.Search = ConflictDirectedBackjumping
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, success)
+}
@@ -16,6 +16,7 @@ package centipede
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	"golang.org/x/exp/constraints"
 )
@@ -24,6 +25,23 @@ import (
 type Constraint[T comparable] struct {
 	Vars               VariableNames
 	ConstraintFunction VariablesConstraintFunction[T]
+	// IndexedFunction optional faster version of ConstraintFunction used by the
+	// solver, which is given the position of each of Vars in the Variables
+	IndexedFunction IndexedConstraintFunction[T]
+	// Weight penalty for violating the constraint, used by the solvers that
+	// minimise violations rather than require every constraint to hold.
+	// 0 is treated as 1.
+	Weight float64
+	// Soft the constraint may be violated. Solve ignores soft constraints,
+	// and SolveMaxCSP minimises the total Weight of those violated.
+	Soft bool
+	// Name optional description of the constraint, used in explanations.
+	// The generators in this package name the constraints they create.
+	Name string
+	// Priority level of a soft constraint in a constraint hierarchy.
+	// SolveMaxCSP satisfies as much of each level as it can before
+	// considering any lower level.
+	Priority Priority
 }
 
 // Constraints collection type for Constraint
@@ -32,6 +50,49 @@ type Constraints[T comparable] []Constraint[T]
 // VariablesConstraintFunction function used to determine validity of Variables
 type VariablesConstraintFunction[T comparable] func(variables *Variables[T]) bool
 
+// IndexedConstraintFunction function used to determine validity of Variables,
+// where indices[i] is the position of the constraint's Vars[i] in variables.
+// Use variables.At(indices[i]) to look up variables without searching by name.
+type IndexedConstraintFunction[T comparable] func(variables *Variables[T], indices []int) bool
+
+// NewIndexedConstraint create a Constraint from an IndexedConstraintFunction.
+// The ConstraintFunction of the result looks up indices by name the first
+// time it is called, and again only when the Variables it is given no longer
+// have those names at those positions, so the constraint can still be
+// evaluated against any Variables.
+func NewIndexedConstraint[T comparable](vars VariableNames, fx IndexedConstraintFunction[T]) Constraint[T] {
+	// shared by every copy of the constraint, which may run concurrently
+	var cached atomic.Pointer[[]int]
+	return Constraint[T]{Vars: vars, IndexedFunction: fx, ConstraintFunction: func(variables *Variables[T]) bool {
+		if indices := cached.Load(); indices != nil && variables.at(vars, *indices) {
+			return fx(variables, *indices)
+		}
+		indices := make([]int, len(vars))
+		for i, name := range vars {
+			indices[i] = variables.IndexOf(name)
+			if indices[i] < 0 {
+				panic(fmt.Sprintf("Variable not found by name %v in variables %v", name, variables))
+			}
+		}
+		cached.Store(&indices)
+		return fx(variables, indices)
+	}}
+}
+
+// named give a constraint a name
+func named[T comparable](name string, constraint Constraint[T]) Constraint[T] {
+	constraint.Name = name
+	return constraint
+}
+
+// describe the constraint's Name, or a description of the variables it constrains
+func (constraint *Constraint[T]) describe() string {
+	if constraint.Name != "" {
+		return constraint.Name
+	}
+	return fmt.Sprintf("constraint on %v", constraint.Vars)
+}
+
 // AllSatisfied check if a collection of Constraints are satisfied
 func (constraints *Constraints[T]) AllSatisfied(variables *Variables[T]) bool {
 	flag := true
@@ -63,30 +124,30 @@ func (constraints *Constraints[T]) FilterByOrder(order int) Constraints[T] {
 	return filtered
 }
 
+// weight Weight or its default
+func (constraint *Constraint[T]) weight() float64 {
+	if constraint.Weight > 0 {
+		return constraint.Weight
+	}
+	return 1
+}
+
 // Satisfied checks to see if the given Constraint is satisfied by the variables presented
 func (constraint *Constraint[T]) Satisfied(variables *Variables[T]) bool {
-	constraintVariablesSatisfied := true
-	domainSatisfied := true
-
 	for _, varname := range constraint.Vars {
 		// make sure Variables contains an object for each name in Constraint.Vars
-		constraintVariablesSatisfied = constraintVariablesSatisfied && (variables.Contains(varname))
+		if !variables.Contains(varname) {
+			panic(fmt.Sprintf("Insufficient variables provided. Expected %v", constraint.Vars))
+		}
 	}
 
 	for _, variable := range *variables {
 		// make sure each Variable being passed in has a value consistent with its domain or is empty
-		domainSatisfied = domainSatisfied && (variable.Domain.Contains(variable.Value) || variable.Empty)
-
-		if !variable.Domain.Contains(variable.Value) && !variable.Empty {
-			fmt.Printf("Variable %v with domain %v does not support value %v\n", variable.Name, variable.Domain, variable.Value)
+		if !variable.Empty && !variable.Domain.Contains(variable.Value) {
+			panic(fmt.Sprintf("Variables do not satisfy the domains given. Variable %v with domain %v does not support value %v",
+				variable.Name, variable.Domain, variable.Value))
 		}
 	}
-	if !constraintVariablesSatisfied {
-		panic(fmt.Sprintf("Insufficient variables provided. Expected %v", constraint.Vars))
-	}
-	if !domainSatisfied {
-		panic("Variables do not satisfy the domains given.")
-	}
 
 	// now finally call the constraint function
 	return constraint.ConstraintFunction(variables)
@@ -94,82 +155,90 @@ func (constraint *Constraint[T]) Satisfied(variables *Variables[T]) bool {
 
 // Equals Constraint generator that checks if two vars are equal
 func Equals[T comparable](var1 VariableName, var2 VariableName) Constraint[T] {
-	return Constraint[T]{VariableNames{var1, var2}, func(variables *Variables[T]) bool {
-		if variables.Find(var1).Empty || variables.Find(var2).Empty {
+	return named(fmt.Sprintf("%v == %v", var1, var2), NewIndexedConstraint(VariableNames{var1, var2}, func(variables *Variables[T], indices []int) bool {
+		variable1, variable2 := variables.At(indices[0]), variables.At(indices[1])
+		if variable1.Empty || variable2.Empty {
 			return true
 		}
-		return variables.Find(var1).Value == variables.Find(var2).Value
-	}}
+		return variable1.Value == variable2.Value
+	}))
 }
 
 // NotEquals Constraint generator that checks if two vars are not equal
 func NotEquals[T comparable](var1 VariableName, var2 VariableName) Constraint[T] {
-	return Constraint[T]{VariableNames{var1, var2}, func(variables *Variables[T]) bool {
-		if variables.Find(var1).Empty || variables.Find(var2).Empty {
+	return named(fmt.Sprintf("%v != %v", var1, var2), NewIndexedConstraint(VariableNames{var1, var2}, func(variables *Variables[T], indices []int) bool {
+		variable1, variable2 := variables.At(indices[0]), variables.At(indices[1])
+		if variable1.Empty || variable2.Empty {
 			return true
 		}
-		return variables.Find(var1).Value != variables.Find(var2).Value
-	}}
+		return variable1.Value != variable2.Value
+	}))
 }
 
 // UnaryEquals Unary constraint that checks if var1 equals some constant
 func UnaryEquals[T comparable](var1 VariableName, value interface{}) Constraint[T] {
-	return Constraint[T]{VariableNames{var1}, func(variables *Variables[T]) bool {
-		if variables.Find(var1).Empty {
+	return named(fmt.Sprintf("%v == %v", var1, value), NewIndexedConstraint(VariableNames{var1}, func(variables *Variables[T], indices []int) bool {
+		variable1 := variables.At(indices[0])
+		if variable1.Empty {
 			return true
 		}
-		return variables.Find(var1).Value == value
-	}}
+		return variable1.Value == value
+	}))
 }
 
 // UnaryNotEquals Unary constraint that checks if var1 is not equal to some constant
 func UnaryNotEquals[T comparable](var1 VariableName, value interface{}) Constraint[T] {
-	return Constraint[T]{VariableNames{var1}, func(variables *Variables[T]) bool {
-		if variables.Find(var1).Empty {
+	return named(fmt.Sprintf("%v != %v", var1, value), NewIndexedConstraint(VariableNames{var1}, func(variables *Variables[T], indices []int) bool {
+		variable1 := variables.At(indices[0])
+		if variable1.Empty {
 			return true
 		}
-		return variables.Find(var1).Value != value
-	}}
+		return variable1.Value != value
+	}))
 }
 
 // LessThan Constraint generator that checks if first variable is less than second variable
 func LessThan[T constraints.Integer | constraints.Float](var1 VariableName, var2 VariableName) Constraint[T] {
-	return Constraint[T]{VariableNames{var1, var1}, func(variables *Variables[T]) bool {
-		if variables.Find(var1).Empty || variables.Find(var2).Empty {
+	return named(fmt.Sprintf("%v < %v", var1, var2), NewIndexedConstraint(VariableNames{var1, var2}, func(variables *Variables[T], indices []int) bool {
+		variable1, variable2 := variables.At(indices[0]), variables.At(indices[1])
+		if variable1.Empty || variable2.Empty {
 			return true
 		}
-		return variables.Find(var1).Value < variables.Find(var2).Value
-	}}
+		return variable1.Value < variable2.Value
+	}))
 }
 
-// GreaterThan Constraint generator that checks if first variable is less than second variable
+// GreaterThan Constraint generator that checks if first variable is greater than second variable
 func GreaterThan[T constraints.Integer | constraints.Float](var1 VariableName, var2 VariableName) Constraint[T] {
-	return Constraint[T]{VariableNames{var1, var1}, func(variables *Variables[T]) bool {
-		if variables.Find(var1).Empty || variables.Find(var2).Empty {
+	return named(fmt.Sprintf("%v > %v", var1, var2), NewIndexedConstraint(VariableNames{var1, var2}, func(variables *Variables[T], indices []int) bool {
+		variable1, variable2 := variables.At(indices[0]), variables.At(indices[1])
+		if variable1.Empty || variable2.Empty {
 			return true
 		}
-		return variables.Find(var1).Value > variables.Find(var2).Value
-	}}
+		return variable1.Value > variable2.Value
+	}))
 }
 
 // LessThanOrEqualTo Constraint generator that checks if first variable is less than or equal to second variable
 func LessThanOrEqualTo[T constraints.Integer | constraints.Float](var1 VariableName, var2 VariableName) Constraint[T] {
-	return Constraint[T]{VariableNames{var1, var1}, func(variables *Variables[T]) bool {
-		if variables.Find(var1).Empty || variables.Find(var2).Empty {
+	return named(fmt.Sprintf("%v <= %v", var1, var2), NewIndexedConstraint(VariableNames{var1, var2}, func(variables *Variables[T], indices []int) bool {
+		variable1, variable2 := variables.At(indices[0]), variables.At(indices[1])
+		if variable1.Empty || variable2.Empty {
 			return true
 		}
-		return variables.Find(var1).Value <= variables.Find(var2).Value
-	}}
+		return variable1.Value <= variable2.Value
+	}))
 }
 
-// GreaterThanOrEqualTo Constraint generator that checks if first variable is less than or equal to second variable
+// GreaterThanOrEqualTo Constraint generator that checks if first variable is greater than or equal to second variable
 func GreaterThanOrEqualTo[T constraints.Integer | constraints.Float](var1 VariableName, var2 VariableName) Constraint[T] {
-	return Constraint[T]{VariableNames{var1, var1}, func(variables *Variables[T]) bool {
-		if variables.Find(var1).Empty || variables.Find(var2).Empty {
+	return named(fmt.Sprintf("%v >= %v", var1, var2), NewIndexedConstraint(VariableNames{var1, var2}, func(variables *Variables[T], indices []int) bool {
+		variable1, variable2 := variables.At(indices[0]), variables.At(indices[1])
+		if variable1.Empty || variable2.Empty {
 			return true
 		}
-		return variables.Find(var1).Value >= variables.Find(var2).Value
-	}}
+		return variable1.Value >= variable2.Value
+	}))
 }
 
 // AllEquals Constraint generator that checks that all given variables are equal
@@ -14,9 +14,153 @@
 
 package centipede
 
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
 // CSPState state object for CSP Solver
 type CSPState[T comparable] struct {
 	Vars        Variables[T]
 	Constraints Constraints[T]
 	Propagations[T]
+	// Logger optional logger for diagnostic output. At debug level the
+	// solver traces assignments, domain prunings and backtracks.
+	Logger *slog.Logger
+	index  stateIndex
+	// provenance why each value pruned by local consistency was removed
+	provenance *provenance[T]
+	// unpruned the domains from before local consistency, for UnsatCore
+	unpruned *unpruned[T]
+}
+
+// Copy return a copy of the state that can be solved independently of the
+// original. Constraint and propagation functions are shared between them.
+func (state *CSPState[T]) Copy() CSPState[T] {
+	copied := CSPState[T]{
+		Vars:         state.Vars.Copy(),
+		Constraints:  append(Constraints[T]{}, state.Constraints...),
+		Propagations: append(Propagations[T]{}, state.Propagations...),
+		Logger:       state.Logger,
+	}
+	if state.unpruned != nil {
+		copied.unpruned = state.unpruned.copy()
+	}
+	return copied
+}
+
+// stateIndex lookups compiled from the variable and constraint names
+type stateIndex struct {
+	// vars position of each variable in Vars
+	vars VariableIndex
+	// constraints position in Vars of each constraint's variables
+	constraints [][]int
+	// incident positions in Constraints of the constraints on each variable
+	incident [][]int
+	// global positions in Constraints of the constraints with no Vars,
+	// which could depend on any variable
+	global []int
+	// debug whether the Logger accepts debug-level messages
+	debug bool
+}
+
+// compile build the name lookups for the current Vars and Constraints.
+// Must be called again if either is changed.
+func (state *CSPState[T]) compile() {
+	state.index.debug = state.Logger != nil && state.Logger.Enabled(context.Background(), slog.LevelDebug)
+	state.index.vars = state.Vars.Index()
+	state.index.constraints = make([][]int, len(state.Constraints))
+	state.index.incident = make([][]int, len(state.Vars))
+	state.index.global = nil
+	for i, constraint := range state.Constraints {
+		if len(constraint.Vars) == 0 {
+			state.index.global = append(state.index.global, i)
+		}
+		indices := make([]int, len(constraint.Vars))
+		for j, name := range constraint.Vars {
+			index, ok := state.index.vars[name]
+			if !ok {
+				panic(fmt.Sprintf("Insufficient variables provided. Expected %v", constraint.Vars))
+			}
+			indices[j] = index
+			// a variable may be listed more than once in the same constraint
+			incident := state.index.incident[index]
+			if len(incident) == 0 || incident[len(incident)-1] != i {
+				state.index.incident[index] = append(incident, i)
+			}
+		}
+		state.index.constraints[i] = indices
+	}
+}
+
+// trace log a debug-level message. Callers on hot paths should check
+// state.index.debug first to avoid building the arguments.
+func (state *CSPState[T]) trace(msg string, args ...any) {
+	if state.index.debug {
+		state.Logger.Debug(msg, args...)
+	}
+}
+
+// find look up a variable by name using the compiled index when possible
+func (state *CSPState[T]) find(name VariableName) *Variable[T] {
+	if index, ok := state.index.vars[name]; ok && index < len(state.Vars) && state.Vars[index].Name == name {
+		return &state.Vars[index]
+	}
+	return state.Vars.Find(name)
+}
+
+// satisfied evaluate the constraint at the given position, using its
+// IndexedFunction if it has one
+func (state *CSPState[T]) satisfied(index int) bool {
+	constraint := &state.Constraints[index]
+	if constraint.IndexedFunction != nil {
+		return constraint.IndexedFunction(&state.Vars, state.index.constraints[index])
+	}
+	return constraint.ConstraintFunction(&state.Vars)
+}
+
+// consistent check only the constraints on the variable at the given
+// position. If every constraint was satisfied before that variable was
+// assigned, this is equivalent to checking all of them.
+func (state *CSPState[T]) consistent(variable int) bool {
+	return state.violated(variable) < 0
+}
+
+// violated position of the first unsatisfied hard constraint on the variable
+// at the given position, or on no variables in particular, or -1 if they are
+// all satisfied
+func (state *CSPState[T]) violated(variable int) int {
+	for _, constraints := range [][]int{state.index.incident[variable], state.index.global} {
+		for _, index := range constraints {
+			if !state.Constraints[index].Soft && !state.satisfied(index) {
+				return index
+			}
+		}
+	}
+	return -1
+}
+
+// allSatisfied check that every variable is consistent with its domain and
+// every hard constraint is satisfied
+func (state *CSPState[T]) allSatisfied() bool {
+	for _, variable := range state.Vars {
+		if !variable.Empty && !variable.Domain.Contains(variable.Value) {
+			panic(fmt.Sprintf("Variables do not satisfy the domains given. Variable %v with domain %v does not support value %v",
+				variable.Name, variable.Domain, variable.Value))
+		}
+	}
+	return state.hardSatisfied()
+}
+
+// hardSatisfied check that every hard constraint is satisfied. The search
+// checks this once every variable is assigned, in case a constraint reads
+// variables missing from its Vars.
+func (state *CSPState[T]) hardSatisfied() bool {
+	for i := range state.Constraints {
+		if !state.Constraints[i].Soft && !state.satisfied(i) {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,184 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrSatisfiable the problem has a solution, so there is nothing to explain
+	ErrSatisfiable error = errors.New("problem is satisfiable")
+)
+
+// UnsatCore a set of constraints, pre-assigned variables and propagations
+// that cannot all hold at once. It is minimal: leaving out any one of them
+// would make the rest satisfiable.
+type UnsatCore[T comparable] struct {
+	Constraints Constraints[T]
+	// Assignments pre-assigned variables involved in the conflict
+	Assignments []VariableAssignment[T]
+	// Propagations propagations whose pruning is part of the conflict
+	Propagations Propagations[T]
+}
+
+// Explain describe the conflict in words, naming each constraint by its
+// Name, or by the variables it constrains if it has none
+func (core *UnsatCore[T]) Explain() string {
+	var explanation strings.Builder
+	if len(core.Constraints) == 0 && len(core.Assignments) == 0 && len(core.Propagations) == 0 {
+		return "there is no solution: the variables' domains cannot be satisfied"
+	}
+	explanation.WriteString("there is no solution because these cannot all hold:")
+	for _, constraint := range core.Constraints {
+		explanation.WriteString("\n  - " + constraint.describe())
+	}
+	for _, assignment := range core.Assignments {
+		explanation.WriteString(fmt.Sprintf("\n  - %v is set to %v", assignment.VariableName, assignment.Value))
+	}
+	for _, propagation := range core.Propagations {
+		explanation.WriteString(fmt.Sprintf("\n  - propagation on %v", propagation.Vars))
+	}
+	return explanation.String()
+}
+
+// UnsatCore find out why the problem has no solution, by narrowing the hard
+// constraints, pre-assigned variables and propagations down to a minimal
+// subset that still has none, using QuickXplain. Each step solves a smaller
+// problem, so this can take much longer than Solve. The domains are taken as
+// they were before MakeArcConsistent or SimplifyPreAssignment pruned them,
+// since the pruning may rest on constraints left out of a step, and values
+// assigned by SimplifyPreAssignment don't count as pre-assigned. Returns
+// ErrSatisfiable if there is a solution.
+func (state *CSPState[T]) UnsatCore(ctx context.Context) (UnsatCore[T], error) {
+	vars := make(Variables[T], len(state.Vars))
+	for i, variable := range state.Vars {
+		vars[i] = state.unprunedVariable(variable)
+	}
+	// items are hard constraints by position, then pre-assigned variables
+	// numbered on from the end of Constraints, then propagations numbered on
+	// from the end of Vars
+	assignments, propagations := len(state.Constraints), len(state.Constraints)+len(vars)
+	items := make([]int, 0)
+	for c, constraint := range state.Constraints {
+		if !constraint.Soft {
+			items = append(items, c)
+		}
+	}
+	for i, variable := range vars {
+		if !variable.Empty {
+			items = append(items, assignments+i)
+		}
+	}
+	for p := range state.Propagations {
+		items = append(items, propagations+p)
+	}
+	satisfiable := func(subset []int) (bool, error) {
+		candidate := CSPState[T]{Vars: vars.Copy(), Constraints: Constraints[T]{}, Propagations: Propagations[T]{}}
+		assigned := make([]bool, len(vars))
+		for _, item := range subset {
+			switch {
+			case item < assignments:
+				candidate.Constraints = append(candidate.Constraints, state.Constraints[item])
+			case item < propagations:
+				assigned[item-assignments] = true
+			default:
+				candidate.Propagations = append(candidate.Propagations, state.Propagations[item-propagations])
+			}
+		}
+		for i := range candidate.Vars {
+			if !assigned[i] {
+				candidate.Vars[i].Unset()
+			}
+		}
+		return candidate.satisfiable(ctx, SolverOptions{})
+	}
+
+	core := UnsatCore[T]{Constraints: Constraints[T]{}, Assignments: []VariableAssignment[T]{}, Propagations: Propagations[T]{}}
+	if ok, err := satisfiable(items); err != nil || ok {
+		if err == nil {
+			err = ErrSatisfiable
+		}
+		return core, err
+	}
+	// the domains alone may already have no solution
+	conflict, err := quickXplain(nil, true, items, satisfiable)
+	if err != nil {
+		return core, err
+	}
+	for _, item := range conflict {
+		switch {
+		case item < assignments:
+			core.Constraints = append(core.Constraints, state.Constraints[item])
+		case item < propagations:
+			variable := &vars[item-assignments]
+			core.Assignments = append(core.Assignments, VariableAssignment[T]{variable.Name, variable.Value})
+		default:
+			core.Propagations = append(core.Propagations, state.Propagations[item-propagations])
+		}
+	}
+	return core, nil
+}
+
+// satisfiable whether the problem has a solution, found with a backtracking
+// search on a copy of the state using the given options. Nogoods are not
+// learned, since the problem is only a variation on the real one. Returns
+// ErrSearchLimit if the search gave up without an answer.
+func (state *CSPState[T]) satisfiable(ctx context.Context, options SolverOptions) (bool, error) {
+	solver := BackTrackingCSPSolver[T]{State: *state, Options: options}
+	solver.Options.LearnNogoods = false
+	s := newSearch(ctx, &solver)
+	solved := s.run()
+	if s.aborted {
+		return false, ErrExecutionCanceled
+	}
+	if !solved && s.limited {
+		return false, ErrSearchLimit
+	}
+	return solved, nil
+}
+
+// quickXplain find a minimal subset of items that, together with background,
+// is not satisfiable, given that background plus all of items is not.
+// changed says whether background has changed since it was last tested.
+func quickXplain(background []int, changed bool, items []int, satisfiable func([]int) (bool, error)) ([]int, error) {
+	if changed {
+		ok, err := satisfiable(background)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			// the background is already a conflict without any of items
+			return []int{}, nil
+		}
+	}
+	if len(items) <= 1 {
+		return items, nil
+	}
+	half := len(items) / 2
+	first, second := items[:half], items[half:]
+	secondConflict, err := quickXplain(append(append([]int{}, background...), first...), true, second, satisfiable)
+	if err != nil {
+		return nil, err
+	}
+	firstConflict, err := quickXplain(append(append([]int{}, background...), secondConflict...), len(secondConflict) > 0, first, satisfiable)
+	if err != nil {
+		return nil, err
+	}
+	return append(firstConflict, secondConflict...), nil
+}
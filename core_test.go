@@ -0,0 +1,124 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnsatCore(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(0, 3)),
+		NewVariable("B", IntRange(0, 3)),
+		NewVariable("C", IntRange(0, 3)),
+		NewVariable("D", IntRange(0, 3)),
+	}
+	vars.SetValue("D", 1)
+	constraints := Constraints[int]{
+		LessThan[int]("A", "B"),
+		NotEquals[int]("C", "D"),
+		LessThan[int]("B", "C"),
+		// unrelated to the conflict
+		GreaterThan[int]("D", "A"),
+		UnaryNotEquals[int]("A", 2),
+		// C has to be 2 for A < B < C, and this rules it out
+		UnaryNotEquals[int]("C", 2),
+	}
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, success)
+
+	core, err := solver.State.UnsatCore(context.TODO())
+	assert.Nil(t, err)
+	names := make([]string, 0)
+	for _, constraint := range core.Constraints {
+		names = append(names, constraint.Name)
+	}
+	assert.ElementsMatch(t, []string{"A < B", "B < C", "C != 2"}, names)
+	assert.Empty(t, core.Assignments)
+	assert.Equal(t, "there is no solution because these cannot all hold:\n  - A < B\n  - B < C\n  - C != 2", core.Explain())
+
+	// here the pre-assignment of D is to blame as well
+	solver.State.Constraints = Constraints[int]{
+		LessThan[int]("A", "B"),
+		UnaryNotEquals[int]("A", 2),
+		LessThan[int]("B", "C"),
+		NewIndexedConstraint(VariableNames{"C", "D"}, func(variables *Variables[int], indices []int) bool {
+			c, d := variables.At(indices[0]), variables.At(indices[1])
+			return c.Empty || d.Empty || c.Value != d.Value+1
+		}),
+	}
+	core, err = solver.State.UnsatCore(context.TODO())
+	assert.Nil(t, err)
+	assert.Len(t, core.Constraints, 3)
+	assert.Equal(t, []VariableAssignment[int]{{"D", 1}}, core.Assignments)
+	assert.Contains(t, core.Explain(), "constraint on [C D]")
+	assert.Contains(t, core.Explain(), "D is set to 1")
+}
+
+func TestUnsatCoreSatisfiable(t *testing.T) {
+	vars, constraints := queensProblem(4)
+	state := CSPState[int]{Vars: vars, Constraints: constraints}
+	_, err := state.UnsatCore(context.TODO())
+	assert.ErrorIs(t, err, ErrSatisfiable)
+
+	// nothing to blame but an empty domain
+	state = CSPState[int]{Vars: Variables[int]{NewVariable("A", Domain[int]{})}, Constraints: Constraints[int]{UnaryEquals[int]("A", 1)}}
+	core, err := state.UnsatCore(context.TODO())
+	assert.Nil(t, err)
+	assert.Empty(t, core.Constraints)
+	assert.Equal(t, "there is no solution: the variables' domains cannot be satisfied", core.Explain())
+}
+
+func TestUnsatCorePruned(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(0, 3)),
+		NewVariable("B", IntRange(0, 3)),
+		NewVariable("C", IntRange(0, 3)),
+	}
+	state := CSPState[int]{Vars: vars, Constraints: Constraints[int]{
+		LessThan[int]("A", "B"),
+		LessThan[int]("B", "C"),
+		UnaryNotEquals[int]("C", 2),
+	}}
+	// C is left with only 2, but that was down to A < B < C
+	assert.Nil(t, state.MakeArcConsistent(context.TODO()))
+	assert.Equal(t, Domain[int]{2}, state.Vars.Find("C").Domain)
+	core, err := state.UnsatCore(context.TODO())
+	assert.Nil(t, err)
+	assert.Len(t, core.Constraints, 3)
+
+	// whatever A is, B can't be, so B can't be 0
+	vars = Variables[int]{
+		NewVariable("A", Domain[int]{0}),
+		NewVariable("B", IntRange(0, 2)),
+	}
+	propagations := Propagations[int]{{Vars: VariableNames{"A", "B"}, PropagationFunction: func(assignment VariableAssignment[int], variables *Variables[int]) []DomainRemoval[int] {
+		if assignment.VariableName != "A" {
+			return nil
+		}
+		return []DomainRemoval[int]{{VariableName: "B", Value: assignment.Value}}
+	}}}
+	state = CSPState[int]{Vars: vars, Constraints: Constraints[int]{UnaryEquals[int]("B", 0)}, Propagations: propagations}
+	core, err = state.UnsatCore(context.TODO())
+	assert.Nil(t, err)
+	assert.Len(t, core.Constraints, 1)
+	assert.Len(t, core.Propagations, 1)
+	assert.Equal(t, "there is no solution because these cannot all hold:\n  - B == 0\n  - propagation on [A B]", core.Explain())
+}
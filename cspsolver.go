@@ -18,84 +18,123 @@ import "context"
 
 // BackTrackingCSPSolver struct for holding solver state
 type BackTrackingCSPSolver[T comparable] struct {
-	State CSPState[T]
+	State   CSPState[T]
+	Options SolverOptions
+	// Hints preferred value for each variable, tried before the rest of its domain
+	Hints map[VariableName]T
+	// nogoods learned or imported nogoods, kept between calls to Solve
+	nogoods *nogoodDatabase[T]
+	// heuristics constraint weights and variable activities, kept between calls to Solve
+	heuristics *heuristicState
+	// incremental variables and last solution of Resolve, kept between calls
+	incremental *incrementalState[T]
+	// generation bumped each time the model is changed through the solver's
+	// methods, so the heuristics can tell whether they are up to date
+	generation int
+}
+
+// SolverOptions configuration for BackTrackingCSPSolver. The zero value
+// is plain chronological backtracking.
+type SolverOptions struct {
+	// Search the search strategy to use
+	Search SearchStrategy
+	// MaxDiscrepancies stop LimitedDiscrepancySearch after the pass allowing
+	// this many discrepancies. 0 keeps going until the tree has been searched.
+	MaxDiscrepancies int
+	// LearnNogoods record the assignments responsible for each failure as a
+	// nogood, and use these to prune later branches of the search
+	LearnNogoods bool
+	// NogoodLimit maximum number of nogoods to keep. Defaults to DefaultNogoodLimit.
+	NogoodLimit int
+	// NogoodEviction which nogood to forget when the limit is reached
+	NogoodEviction EvictionPolicy
+	// VariableOrdering heuristic for choosing the next variable to assign
+	VariableOrdering VariableOrdering
+	// Randomize break ties between equally ranked variables at random, and
+	// try each variable's values in a random order
+	Randomize bool
+	// Seed seed for the random choices made when Randomize is set
+	Seed int64
+	// Restarts schedule of cutoffs after which the search starts over
+	Restarts RestartSchedule
+	// RestartLimit whether cutoffs count failures or nodes
+	RestartLimit RestartLimit
+	// RestartScale cutoff of the first run. Defaults to DefaultRestartScale.
+	RestartScale int
+	// RestartFactor growth of each cutoff for GeometricRestarts. Defaults to DefaultRestartFactor.
+	RestartFactor float64
+}
+
+// nogoodLimit NogoodLimit or its default
+func (options *SolverOptions) nogoodLimit() int {
+	if options.NogoodLimit > 0 {
+		return options.NogoodLimit
+	}
+	return DefaultNogoodLimit
 }
 
 // NewBackTrackingCSPSolver create a solver
 func NewBackTrackingCSPSolver[T comparable](vars Variables[T], constraints Constraints[T]) BackTrackingCSPSolver[T] {
-	return BackTrackingCSPSolver[T]{CSPState[T]{vars, constraints, []Propagation[T]{}}}
+	return BackTrackingCSPSolver[T]{State: CSPState[T]{Vars: vars, Constraints: constraints, Propagations: []Propagation[T]{}}}
 }
 
 // NewBackTrackingCSPSolverWithPropagation create a solver
 func NewBackTrackingCSPSolverWithPropagation[T comparable](vars Variables[T], constraints Constraints[T], propagations Propagations[T]) BackTrackingCSPSolver[T] {
-	return BackTrackingCSPSolver[T]{CSPState[T]{vars, constraints, propagations}}
+	return BackTrackingCSPSolver[T]{State: CSPState[T]{Vars: vars, Constraints: constraints, Propagations: propagations}}
 }
 
 // Solve solves for values in the CSP
 func (solver *BackTrackingCSPSolver[T]) Solve(ctx context.Context) (bool, error) {
 	b, err := RunWithContext(ctx, func() bool {
-		return reduce(&solver.State)
+		return newSearch(ctx, solver).run()
 	})
 	if b != nil && *b {
 		return true, nil
 	}
+	if err == nil && ctx.Err() != nil {
+		// the search gave up because the context finished
+		err = ErrExecutionCanceled
+	}
 	return false, err
 }
 
-// implements backtracking search
-func reduce[T comparable](state *CSPState[T]) bool {
-	complete := state.Vars.Complete()
-	satisfied := state.Constraints.AllSatisfied(&state.Vars)
-	if complete && satisfied {
-		return true
+// Solutions find every solution to the CSP. Solutions are always searched
+// for with chronological backtracking, without restarts. State is left
+// unassigned afterwards.
+func (solver *BackTrackingCSPSolver[T]) Solutions(ctx context.Context) ([]Variables[T], error) {
+	solutions := make([]Variables[T], 0)
+	_, err := solver.enumerate(ctx, func() bool {
+		solutions = append(solutions, solver.State.Vars.Copy())
+		return false
+	})
+	if err != nil {
+		return nil, err
 	}
+	return solutions, nil
+}
 
-	// iterate over unassigned variables
-	for i := range state.Vars {
-		// ignore variables that have been set
-		if state.Vars[i].Empty {
-			// iterate over options in the domain
-			domainRemovals := make(DomainRemovals[T], 0)
-			variableDomain := state.Vars[i].Domain
-			for _, option := range variableDomain {
-				// undo any attempts to do domain propagation
-				state.Vars.ResetDomainRemovalEvaluation(domainRemovals)
-
-				// set variable
-				state.Vars[i].SetValue(option)
-
-				// get the propagations
-				domainRemovals = state.Propagations.Execute(VariableAssignment[T]{state.Vars[i].Name, option}, &state.Vars)
-				// propagate through the rest of the variables
-				state.Vars.EvaluateDomainRemovals(domainRemovals)
-
-				// check if this is valid
-				complete := state.Vars.Complete()
-				satisfied := state.Constraints.AllSatisfied(&state.Vars)
-
-				if complete && satisfied {
-					// we have a full solution
-					return true
-				} else if complete && !satisfied {
-					// we have filled it in completely.
-					// keep looping over the domain, but if that fails, we'll bottom out
-					continue
-				} else if !complete && satisfied {
-					// go down a level to assign to another variable
-					if reduce(state) {
-						return true
-					}
-				} else { // !complete && !satisfied
-					continue // keep looping over the domain, but if that fails we'll bottom out
-				}
-			}
-			// reset domain removals
-			state.Vars.ResetDomainRemovalEvaluation(domainRemovals)
-			// unset variable and try with a different one first
-			state.Vars[i].Unset()
-
-		}
+// CountSolutions count the solutions to the CSP without keeping them
+func (solver *BackTrackingCSPSolver[T]) CountSolutions(ctx context.Context) (int, error) {
+	count := 0
+	_, err := solver.enumerate(ctx, func() bool {
+		count++
+		return false
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
 
+// enumerate run the search, calling onSolution for each solution found
+func (solver *BackTrackingCSPSolver[T]) enumerate(ctx context.Context, onSolution func() bool) (bool, error) {
+	b, err := RunWithContext(ctx, func() bool {
+		s := newSearch(ctx, solver)
+		s.onSolution = onSolution
+		return s.run()
+	})
+	if err == nil && ctx.Err() != nil {
+		err = ErrExecutionCanceled
 	}
-	return false
+	return b != nil && *b, err
 }
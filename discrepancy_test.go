@@ -0,0 +1,83 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitedDiscrepancySearch(t *testing.T) {
+	problem := func() (Variables[int], Constraints[int]) {
+		vars := Variables[int]{
+			NewVariable("A", IntRange(0, 5)),
+			NewVariable("B", IntRange(0, 5)),
+			NewVariable("C", IntRange(0, 5)),
+			NewVariable("D", IntRange(0, 5)),
+		}
+		// A = 2 or D = 4
+		constraints := Constraints[int]{
+			Constraint[int]{Vars: VariableNames{"A", "D"}, ConstraintFunction: func(variables *Variables[int]) bool {
+				if variables.Find("A").Empty || variables.Find("D").Empty {
+					return true
+				}
+				return variables.Find("A").Value == 2 || variables.Find("D").Value == 4
+			}},
+		}
+		return vars, constraints
+	}
+
+	// depth-first search finds the solution with D = 4 first (four discrepancies)
+	vars, constraints := problem()
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.Equal(t, 0, solver.State.Vars.Find("A").Value)
+	assert.Equal(t, 4, solver.State.Vars.Find("D").Value)
+
+	// limited discrepancy search finds the one with A = 2 (two discrepancies)
+	vars, constraints = problem()
+	solver = NewBackTrackingCSPSolver(vars, constraints)
+	solver.Options.Search = LimitedDiscrepancySearch
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.Equal(t, 2, solver.State.Vars.Find("A").Value)
+	assert.Equal(t, 0, solver.State.Vars.Find("D").Value)
+
+	// capping the discrepancies can miss solutions
+	vars, constraints = problem()
+	solver = NewBackTrackingCSPSolver(vars, constraints)
+	solver.Options.Search = LimitedDiscrepancySearch
+	solver.Options.MaxDiscrepancies = 1
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, success)
+	assert.Equal(t, 4, solver.State.Vars.Unassigned())
+
+	// unsatisfiable problems still finish once the whole tree is searched
+	solver = NewBackTrackingCSPSolver(Variables[int]{
+		NewVariable("A", IntRange(0, 2)),
+		NewVariable("B", IntRange(0, 2)),
+		NewVariable("C", IntRange(0, 2)),
+	}, AllUnique[int]("A", "B", "C"))
+	solver.Options.Search = LimitedDiscrepancySearch
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, success)
+}
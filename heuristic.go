@@ -0,0 +1,223 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+// VariableOrdering heuristic used to choose the next variable to assign
+type VariableOrdering int
+
+const (
+	// InputOrder assign variables in the order they appear in Vars. This is the default.
+	InputOrder VariableOrdering = iota
+	// MinimumRemainingValues assign the variable with the smallest domain first
+	MinimumRemainingValues
+	// DomOverWeightedDegree assign the variable with the smallest ratio of
+	// domain size to weighted degree first. Every constraint starts with a
+	// weight of 1, which goes up by 1 each time the constraint causes a failure,
+	// so the search focuses on the hardest parts of the problem.
+	DomOverWeightedDegree
+	// ActivityBased assign the variable with the smallest ratio of domain
+	// size to activity first. A variable's activity goes up each time it is
+	// involved in a failure and decays over time.
+	ActivityBased
+)
+
+// activityDecay how much of its activity a variable keeps after each failure
+const activityDecay = 0.95
+
+// heuristicState information learned by the adaptive heuristics. It belongs
+// to the solver, so it is kept across restarts and calls to Solve.
+type heuristicState struct {
+	// weights weight of each constraint for DomOverWeightedDegree
+	weights []float64
+	// activity activity of each variable for ActivityBased
+	activity []float64
+	// increment amount added to activity, which grows instead of decaying
+	// every variable's activity after each failure
+	increment float64
+	// generation the solver's generation everything was learned for
+	generation int
+}
+
+// fit make sure there is a weight and activity for every constraint and
+// variable, starting over if the problem has changed size or the solver has
+// moved on to another generation without bringing the heuristics along
+func (heuristics *heuristicState) fit(variables int, constraints int, generation int) {
+	replaced := generation != heuristics.generation
+	heuristics.generation = generation
+	if replaced || len(heuristics.weights) != constraints {
+		heuristics.weights = make([]float64, constraints)
+		for i := range heuristics.weights {
+			heuristics.weights[i] = 1
+		}
+	}
+	if replaced || len(heuristics.activity) != variables {
+		heuristics.activity = make([]float64, variables)
+		heuristics.increment = 1
+	}
+}
+
+// ResetHeuristics forget the constraint weights and variable activities
+// learned by DomOverWeightedDegree and ActivityBased. They are kept in step
+// with changes made by AddConstraints, RemoveConstraints, AddVariables and
+// RemoveVariables, and start over by themselves when the number of
+// constraints or variables changes any other way, but not when
+// State.Constraints is edited or replaced without changing its length. Call
+// this after doing so.
+func (solver *BackTrackingCSPSolver[T]) ResetHeuristics() {
+	solver.heuristics = nil
+}
+
+// changed move on to the next generation of the model, after a method that
+// has already brought the heuristics in step with the change
+func (solver *BackTrackingCSPSolver[T]) changed() {
+	solver.generation++
+	if solver.heuristics != nil {
+		solver.heuristics.generation = solver.generation
+	}
+}
+
+// failed record a failure caused by the constraint at the given position (or
+// -1 for none) involving the variables at the given positions
+func (heuristics *heuristicState) failed(constraint int, variables []int) {
+	if constraint >= 0 {
+		heuristics.weights[constraint]++
+	}
+	for _, i := range variables {
+		heuristics.activity[i] += heuristics.increment
+	}
+	heuristics.increment /= activityDecay
+	if heuristics.increment > 1e100 {
+		// rescale everything before it overflows
+		for i := range heuristics.activity {
+			heuristics.activity[i] *= 1e-100
+		}
+		heuristics.increment *= 1e-100
+	}
+}
+
+// selectVariable position of the next variable to assign, or -1 if all are assigned
+func (s *search[T]) selectVariable() int {
+	if s.options.VariableOrdering == InputOrder {
+		for i := range s.state.Vars {
+			if s.state.Vars[i].Empty {
+				return i
+			}
+		}
+		return -1
+	}
+
+	best := -1
+	bestScore := 0.0
+	ties := 0
+	for i := range s.state.Vars {
+		if !s.state.Vars[i].Empty {
+			continue
+		}
+		score := s.score(i)
+		switch {
+		case best < 0 || score < bestScore:
+			best, bestScore, ties = i, score, 1
+		case score == bestScore && s.random != nil:
+			// pick uniformly between equally ranked variables
+			ties++
+			if s.random.Intn(ties) == 0 {
+				best = i
+			}
+		}
+	}
+	return best
+}
+
+// score rank of the unassigned variable at position i; lower is assigned first
+func (s *search[T]) score(i int) float64 {
+	domain := float64(len(s.state.Vars[i].Domain))
+	switch s.options.VariableOrdering {
+	case DomOverWeightedDegree:
+		// only count constraints that still have another variable to assign
+		degree := 0.0
+		for _, constraint := range s.state.index.incident[i] {
+			for _, j := range s.state.index.constraints[constraint] {
+				if j != i && s.state.Vars[j].Empty {
+					degree += s.heuristics.weights[constraint]
+					break
+				}
+			}
+		}
+		if degree == 0 {
+			return domain * 2
+		}
+		return domain / degree
+	case ActivityBased:
+		return domain / (1 + s.heuristics.activity[i])
+	default:
+		return domain
+	}
+}
+
+// failed record a failed assignment to the variable at position i, caused by
+// the constraint at the given position or, if that is -1, by a nogood
+func (s *search[T]) failed(i int, constraint int, nogood Nogood[T]) {
+	s.fails++
+	variables := []int{i}
+	if constraint >= 0 {
+		variables = s.state.index.constraints[constraint]
+	} else {
+		for _, assignment := range nogood {
+			variables = append(variables, s.state.index.vars[assignment.VariableName])
+		}
+	}
+	s.heuristics.failed(constraint, variables)
+}
+
+// hints value to try first for the variable at each position: the value in
+// Hints if there is one, or else its value in the last solution from Resolve
+func (solver *BackTrackingCSPSolver[T]) hints() map[int]T {
+	var hints map[int]T
+	add := func(preferred map[VariableName]T) {
+		for name, value := range preferred {
+			if i, ok := solver.State.index.vars[name]; ok {
+				if hints == nil {
+					hints = make(map[int]T)
+				}
+				hints[i] = value
+			}
+		}
+	}
+	if solver.incremental != nil {
+		add(solver.incremental.solution)
+	}
+	add(solver.Hints)
+	return hints
+}
+
+// values the order in which to try values for the variable at position i
+func (s *search[T]) values(i int) Domain[T] {
+	domain := s.state.Vars[i].Domain
+	if s.random != nil {
+		shuffled := append(Domain[T]{}, domain...)
+		s.random.Shuffle(len(shuffled), func(a, b int) { shuffled[a], shuffled[b] = shuffled[b], shuffled[a] })
+		domain = shuffled
+	}
+	if hint, ok := s.hints[i]; ok {
+		for k, value := range domain {
+			if value == hint {
+				// move the hint to the front, keeping the rest in order
+				hinted := append(Domain[T]{hint}, domain[:k]...)
+				return append(hinted, domain[k+1:]...)
+			}
+		}
+	}
+	return domain
+}
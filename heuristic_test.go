@@ -0,0 +1,117 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVariableOrderings(t *testing.T) {
+	for _, ordering := range []VariableOrdering{InputOrder, MinimumRemainingValues, DomOverWeightedDegree, ActivityBased} {
+		vars, constraints := queensProblem(16)
+		solver := NewBackTrackingCSPSolver(vars, constraints)
+		solver.Options.VariableOrdering = ordering
+		success, err := solver.Solve(context.TODO())
+		assert.Nil(t, err)
+		assert.True(t, success)
+		assert.True(t, solver.State.Constraints.AllSatisfied(&solver.State.Vars))
+	}
+
+	// MRV picks the variable with the fewest values left
+	vars := Variables[int]{
+		NewVariable("A", IntRange(0, 5)),
+		NewVariable("B", IntRange(0, 2)),
+		NewVariable("C", IntRange(0, 5)),
+	}
+	solver := NewBackTrackingCSPSolver(vars, Constraints[int]{})
+	solver.Options.VariableOrdering = MinimumRemainingValues
+	search := newSearch(context.TODO(), &solver)
+	assert.Equal(t, 1, search.selectVariable())
+}
+
+func TestWeightedDegreeAcrossRestarts(t *testing.T) {
+	vars, constraints := queensProblem(12)
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	solver.Options.VariableOrdering = DomOverWeightedDegree
+	solver.Options.Restarts = GeometricRestarts
+	solver.Options.RestartScale = 5
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+
+	// the weights learned from failures are still there after solving
+	assert.Greater(t, sumWeights(solver.heuristics.weights), float64(len(constraints)))
+
+	// and are carried over into the next call to Solve
+	weights := append([]float64{}, solver.heuristics.weights...)
+	for i := range solver.State.Vars {
+		solver.State.Vars[i].Unset()
+	}
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	for i := range weights {
+		assert.GreaterOrEqual(t, solver.heuristics.weights[i], weights[i])
+	}
+
+	// nor by a temporary change to the model
+	weights = append([]float64{}, solver.heuristics.weights...)
+	previous := solver.State.Vars.Copy()
+	for i := range solver.State.Vars {
+		solver.State.Vars[i].Unset()
+	}
+	_, err = solver.Repair(context.TODO(), previous)
+	assert.Nil(t, err)
+	assert.Len(t, solver.heuristics.weights, len(weights))
+	for i := range weights {
+		assert.GreaterOrEqual(t, solver.heuristics.weights[i], weights[i])
+	}
+
+	// a change through the solver keeps the weights of the other constraints
+	weights = append([]float64{}, solver.heuristics.weights...)
+	solver.AddConstraints(AllUnique[int]("Q0", "Q1")...)
+	assert.Equal(t, weights, solver.heuristics.weights[:len(weights)])
+	assert.Equal(t, 1.0, solver.heuristics.weights[len(weights)])
+
+	// and can be forgotten on demand
+	heuristics := solver.heuristics
+	solver.ResetHeuristics()
+	assert.Nil(t, solver.heuristics)
+
+	// they start over if the solver has moved on without them
+	heuristics.fit(len(solver.State.Vars), len(solver.State.Constraints), solver.generation+1)
+	assert.Equal(t, len(solver.State.Constraints), int(sumWeights(heuristics.weights)))
+
+	// activity is bumped for the variables of the failing constraint
+	heuristics = &heuristicState{}
+	heuristics.fit(3, 1, 0)
+	heuristics.failed(0, []int{0, 2})
+	heuristics.failed(-1, []int{2})
+	assert.Equal(t, 2.0, heuristics.weights[0])
+	assert.Equal(t, 0.0, heuristics.activity[1])
+	assert.Greater(t, heuristics.activity[2], heuristics.activity[0])
+}
+
+// sumWeights total of the constraint weights
+func sumWeights(weights []float64) float64 {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+	return total
+}
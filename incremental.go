@@ -0,0 +1,242 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import "context"
+
+// incrementalState what Resolve remembers between calls
+type incrementalState[T comparable] struct {
+	// domains full domain of each variable Resolve solves for
+	domains map[VariableName]Domain[T]
+	// solution values of those variables in the last solution found
+	solution map[VariableName]T
+	// added nogood database clock when each named constraint was added, so
+	// that nogoods learned before then can be kept when it is removed
+	added map[string]int
+}
+
+// newIncrementalState remember the domains of the unassigned variables,
+// as they were before local consistency pruned them
+func newIncrementalState[T comparable](state *CSPState[T]) *incrementalState[T] {
+	incremental := &incrementalState[T]{domains: make(map[VariableName]Domain[T]), added: make(map[string]int)}
+	for _, variable := range state.Vars {
+		incremental.record(Variables[T]{state.unprunedVariable(variable)})
+	}
+	return incremental
+}
+
+// record remember the domains of any unassigned variables not seen before
+func (incremental *incrementalState[T]) record(vars Variables[T]) {
+	for _, variable := range vars {
+		if _, ok := incremental.domains[variable.Name]; !ok && variable.Empty {
+			incremental.domains[variable.Name] = append(Domain[T]{}, variable.Domain...)
+		}
+	}
+}
+
+// incrementalState the state kept for Resolve, taken the first time it is
+// needed so that solvers which are never changed don't copy every domain
+func (solver *BackTrackingCSPSolver[T]) incrementalState() *incrementalState[T] {
+	if solver.incremental == nil {
+		solver.incremental = newIncrementalState(&solver.State)
+	}
+	return solver.incremental
+}
+
+// AddConstraints add constraints to the problem solved by Resolve. Learned
+// nogoods are kept, since extra constraints can only rule solutions out.
+func (solver *BackTrackingCSPSolver[T]) AddConstraints(constraints ...Constraint[T]) {
+	solver.State.Constraints = append(solver.State.Constraints, constraints...)
+	incremental, tick := solver.incrementalState(), 0
+	if solver.nogoods != nil {
+		tick = solver.nogoods.tick
+	}
+	for _, constraint := range constraints {
+		if _, ok := incremental.added[constraint.Name]; !ok && constraint.Name != "" {
+			incremental.added[constraint.Name] = tick
+		}
+		if solver.heuristics != nil {
+			solver.heuristics.weights = append(solver.heuristics.weights, 1)
+		}
+	}
+	solver.changed()
+}
+
+// RemoveConstraints remove every constraint with one of the given names.
+// Returns the number removed. Nogoods learned or imported since the first of
+// them was added with AddConstraints are forgotten, since they may have
+// depended on it; for constraints the solver was created with, that is all
+// of them.
+func (solver *BackTrackingCSPSolver[T]) RemoveConstraints(names ...string) int {
+	return solver.removeConstraints(func(constraint *Constraint[T]) bool {
+		return constraint.Name != "" && containsName(names, constraint.Name)
+	})
+}
+
+// removeConstraints remove every constraint for which remove returns true,
+// keeping the learned weights of the rest and the nogoods learned without them
+func (solver *BackTrackingCSPSolver[T]) removeConstraints(remove func(constraint *Constraint[T]) bool) int {
+	incremental := solver.incrementalState()
+	removed, since := 0, -1
+	kept := solver.State.Constraints[:0]
+	weights := make([]float64, 0, len(solver.State.Constraints))
+	for c := range solver.State.Constraints {
+		if constraint := &solver.State.Constraints[c]; remove(constraint) {
+			// unnamed constraints can't be traced, so count them as there
+			// from the start
+			if tick, ok := incremental.added[constraint.Name]; !ok || constraint.Name == "" {
+				since = 0
+			} else if since < 0 || tick < since {
+				since = tick
+			}
+			delete(incremental.added, constraint.Name)
+			removed++
+			continue
+		}
+		kept = append(kept, solver.State.Constraints[c])
+		if solver.heuristics != nil && c < len(solver.heuristics.weights) {
+			weights = append(weights, solver.heuristics.weights[c])
+		}
+	}
+	solver.State.Constraints = kept
+	if removed > 0 {
+		if solver.nogoods != nil {
+			solver.nogoods.forget(func(learned *learnedNogood[T]) bool {
+				return learned.created > since
+			})
+		}
+		if solver.heuristics != nil {
+			solver.heuristics.weights = weights
+		}
+		solver.changed()
+	}
+	return removed
+}
+
+// AddVariables add variables to the problem solved by Resolve. Any that are
+// unassigned are solved for; any that are assigned are fixed.
+func (solver *BackTrackingCSPSolver[T]) AddVariables(variables ...Variable[T]) {
+	solver.State.Vars = append(solver.State.Vars, variables...)
+	solver.incrementalState().record(variables)
+	for range variables {
+		if solver.heuristics != nil {
+			solver.heuristics.activity = append(solver.heuristics.activity, 0)
+		}
+	}
+	solver.changed()
+}
+
+// RemoveVariables remove the named variables from the problem, along with
+// every constraint and propagation on them. Returns the number of variables
+// removed. Nogoods on the variables are forgotten, along with those that
+// might depend on the constraints, as for RemoveConstraints.
+func (solver *BackTrackingCSPSolver[T]) RemoveVariables(names ...VariableName) int {
+	removed, removing := 0, VariableNames(names)
+	kept := solver.State.Vars[:0]
+	activity := make([]float64, 0, len(solver.State.Vars))
+	for i, variable := range solver.State.Vars {
+		if removing.Contains(variable.Name) {
+			removed++
+			delete(solver.incrementalState().domains, variable.Name)
+			delete(solver.incremental.solution, variable.Name)
+			continue
+		}
+		kept = append(kept, variable)
+		if solver.heuristics != nil && i < len(solver.heuristics.activity) {
+			activity = append(activity, solver.heuristics.activity[i])
+		}
+	}
+	if removed == 0 {
+		return 0
+	}
+	solver.State.Vars = kept
+	if solver.heuristics != nil {
+		solver.heuristics.activity = activity
+	}
+	solver.changed()
+	solver.removeConstraints(func(constraint *Constraint[T]) bool {
+		return mentions(constraint.Vars, names)
+	})
+	propagations := solver.State.Propagations[:0]
+	for _, propagation := range solver.State.Propagations {
+		if !mentions(propagation.Vars, names) {
+			propagations = append(propagations, propagation)
+		}
+	}
+	solver.State.Propagations = propagations
+	if solver.nogoods != nil {
+		solver.nogoods.forget(func(learned *learnedNogood[T]) bool {
+			for _, assignment := range learned.nogood {
+				if removing.Contains(assignment.VariableName) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	return removed
+}
+
+// Resolve solve the problem again after it has been changed with
+// AddConstraints, RemoveConstraints, AddVariables or RemoveVariables. The
+// variables that were unassigned the first time Resolve or one of those
+// methods was called, or when they were added, are unassigned again and
+// solved for with their domains as they were then, trying the values from
+// the last solution first, so a small change to the model usually needs
+// little search. Call Resolve rather than Solve the first time, since Solve
+// leaves the variables assigned. Pruning by MakeArcConsistent or
+// SimplifyPreAssignment is undone, since it may have relied on constraints
+// that have since been removed. Learned nogoods that are still valid are kept.
+func (solver *BackTrackingCSPSolver[T]) Resolve(ctx context.Context) (bool, error) {
+	solver.incrementalState().record(solver.State.Vars)
+	for i := range solver.State.Vars {
+		variable := &solver.State.Vars[i]
+		// propagation may have pruned the domain on the way to the last solution
+		if domain, ok := solver.incremental.domains[variable.Name]; ok {
+			variable.Unset()
+			variable.SetDomain(append(Domain[T]{}, domain...))
+		}
+	}
+	solved, err := solver.Solve(ctx)
+	if solved {
+		solver.incremental.solution = make(map[VariableName]T, len(solver.incremental.domains))
+		for _, variable := range solver.State.Vars {
+			if _, ok := solver.incremental.domains[variable.Name]; ok {
+				solver.incremental.solution[variable.Name] = variable.Value
+			}
+		}
+	}
+	return solved, err
+}
+
+// containsName whether names contains name
+func containsName(names []string, name string) bool {
+	for _, item := range names {
+		if item == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mentions whether any of names is among vars
+func mentions(vars VariableNames, names []VariableName) bool {
+	for _, name := range names {
+		if vars.Contains(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveConstraints(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(1, 4)),
+		NewVariable("B", IntRange(1, 4)),
+		NewVariable("C", IntRange(1, 4)),
+	}
+	solver := NewBackTrackingCSPSolver(vars, AllUnique[int]("A", "B", "C"))
+	// nothing is copied until the model is changed or resolved
+	assert.Nil(t, solver.incremental)
+	solved, err := solver.Resolve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, solved)
+	assert.Equal(t, []int{1, 2, 3}, values(solver.State.Vars))
+
+	// a new rule moves A, and B keeps its value from the last solution
+	solver.AddConstraints(named("A is 3", UnaryEquals[int]("A", 3)))
+	solved, err = solver.Resolve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, solved)
+	assert.Equal(t, []int{3, 2, 1}, values(solver.State.Vars))
+
+	// without it again, the last solution is still valid, so it is kept
+	// rather than going back to the first one
+	assert.Equal(t, 1, solver.RemoveConstraints("A is 3"))
+	assert.Equal(t, 0, solver.RemoveConstraints("A is 3"))
+	solved, err = solver.Resolve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, solved)
+	assert.Equal(t, []int{3, 2, 1}, values(solver.State.Vars))
+	assert.Len(t, solver.State.Constraints, 3)
+
+	// a rule that can't be met
+	solver.AddConstraints(named("A is 4", UnaryEquals[int]("A", 4)))
+	solved, err = solver.Resolve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, solved)
+}
+
+func TestResolveVariables(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(1, 4)),
+		NewVariable("B", IntRange(1, 4)),
+	}
+	vars[1].SetValue(1)
+	propagations := Propagations[int]{{Vars: VariableNames{"A", "B"}, PropagationFunction: func(assignment VariableAssignment[int], variables *Variables[int]) []DomainRemoval[int] {
+		// whatever A is, C can't be
+		if assignment.VariableName != "A" || !variables.Contains("C") {
+			return nil
+		}
+		return []DomainRemoval[int]{{VariableName: "C", Value: assignment.Value}}
+	}}}
+	solver := NewBackTrackingCSPSolverWithPropagation(vars, AllUnique[int]("A", "B"), propagations)
+	solved, err := solver.Resolve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, solved)
+	assert.Equal(t, []int{2, 1}, values(solver.State.Vars))
+
+	// B was assigned up front, so it stays fixed; C is solved for
+	solver.AddVariables(NewVariable("C", IntRange(1, 4)))
+	solver.AddConstraints(LessThan[int]("B", "C"))
+	solved, err = solver.Resolve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, solved)
+	assert.Equal(t, []int{2, 1, 3}, values(solver.State.Vars))
+
+	// removing A takes its constraint and propagation with it, and C's
+	// domain is back in full
+	assert.Equal(t, 1, solver.RemoveVariables("A"))
+	assert.Equal(t, "B < C", solver.State.Constraints[0].Name)
+	assert.Len(t, solver.State.Constraints, 1)
+	assert.Empty(t, solver.State.Propagations)
+	solved, err = solver.Resolve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, solved)
+	assert.Equal(t, []int{1, 3}, values(solver.State.Vars))
+	assert.Equal(t, Domain[int]{1, 2, 3}, solver.State.Vars.Find("C").Domain)
+}
+
+func TestResolveNogoods(t *testing.T) {
+	evaluations := 0
+	vars, constraints := pigeonholeProblem(&evaluations)
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	solver.Options.LearnNogoods = true
+	solved, err := solver.Resolve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, solved)
+	learned := solver.Nogoods()
+	assert.NotEmpty(t, learned)
+
+	// nogoods are still valid with an extra constraint, so only the initial
+	// check of the constraints is needed
+	evaluations = 0
+	solver.AddConstraints(named("X0 != X1", NotEquals[int]("X0", "X1")))
+	solved, err = solver.Resolve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, solved)
+	assert.Equal(t, 3, evaluations)
+
+	// once it has gone, only the nogoods from before it was added are kept
+	assert.Nil(t, solver.AddNogoods(Nogood[int]{{"X0", 1}, {"X1", 1}}))
+	assert.Greater(t, len(solver.Nogoods()), len(learned))
+	assert.Equal(t, 1, solver.RemoveConstraints("X0 != X1"))
+	assert.Equal(t, learned, solver.Nogoods())
+
+	// the constraints the solver started with may be behind any of them
+	assert.Equal(t, 1, solver.RemoveVariables("P"))
+	assert.Empty(t, solver.Nogoods())
+}
+
+func TestResolveUnprunes(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(1, 4)),
+		NewVariable("B", IntRange(1, 4)),
+	}
+	solver := NewBackTrackingCSPSolver(vars, Constraints[int]{LessThan[int]("A", "B")})
+	solver.State.MakeArcConsistent(context.TODO())
+	assert.Equal(t, Domain[int]{1, 2}, solver.State.Vars.Find("A").Domain)
+	solved, err := solver.Resolve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, solved)
+
+	// A's domain was only pruned because of A < B
+	assert.Equal(t, 1, solver.RemoveConstraints("A < B"))
+	solver.AddConstraints(named("A is 3", UnaryEquals[int]("A", 3)))
+	solved, err = solver.Resolve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, solved)
+	assert.Equal(t, 3, solver.State.Vars.Find("A").Value)
+}
+
+// values the value of each variable, in order
+func values[T comparable](variables Variables[T]) []T {
+	values := make([]T, len(variables))
+	for i, variable := range variables {
+		values[i] = variable.Value
+	}
+	return values
+}
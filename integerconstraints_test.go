@@ -16,6 +16,7 @@ package centipede
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -66,3 +67,105 @@ func TestIntegerConstraints(t *testing.T) {
 	assert.Equal(t, values["D"], 1)
 	assert.Equal(t, values["E"], 2)
 }
+
+func TestIndexedConstraints(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(1, 5)),
+		NewVariable("B", IntRange(1, 5)),
+		NewVariable("C", IntRange(1, 5)),
+	}
+	index := vars.Index()
+	assert.Equal(t, 2, index["C"])
+	assert.Equal(t, 1, vars.IndexOf("B"))
+	assert.Equal(t, -1, vars.IndexOf("D"))
+	assert.Equal(t, VariableName("A"), vars.At(0).Name)
+
+	constraints := Constraints[int]{
+		LessThan[int]("A", "B"),
+		GreaterThan[int]("C", "B"),
+		// custom constraint using positions instead of names: A + B + C = 9
+		NewIndexedConstraint(VariableNames{"A", "B", "C"}, func(variables *Variables[int], indices []int) bool {
+			sum := 0
+			for _, i := range indices {
+				if variables.At(i).Empty {
+					return true
+				}
+				sum += variables.At(i).Value
+			}
+			return sum == 9
+		}),
+	}
+
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.Equal(t, 2, solver.State.Vars.Find("A").Value)
+	assert.Equal(t, 3, solver.State.Vars.Find("B").Value)
+	assert.Equal(t, 4, solver.State.Vars.Find("C").Value)
+
+	// the ConstraintFunction fallback follows the variables when they move
+	reordered := Variables[int]{*solver.State.Vars.Find("C"), *solver.State.Vars.Find("B"), *solver.State.Vars.Find("A")}
+	assert.True(t, constraints[2].ConstraintFunction(&solver.State.Vars))
+	assert.True(t, constraints[2].ConstraintFunction(&reordered))
+	reordered.SetValue("A", 3)
+	assert.False(t, constraints[2].ConstraintFunction(&reordered))
+}
+
+func TestIncrementalChecking(t *testing.T) {
+	// alternating chain A0 != A1 != A2 ... where each constraint counts its evaluations
+	evaluations := 0
+	vars := make(Variables[int], 0)
+	constraints := make(Constraints[int], 0)
+	for i := 0; i < 50; i++ {
+		vars = append(vars, NewVariable(VariableName(fmt.Sprintf("A%d", i)), IntRange(0, 2)))
+		if i > 0 {
+			notEquals := NotEquals[int](vars[i-1].Name, vars[i].Name)
+			constraints = append(constraints, Constraint[int]{Vars: notEquals.Vars,
+				ConstraintFunction: func(variables *Variables[int]) bool {
+					evaluations++
+					return notEquals.ConstraintFunction(variables)
+				}})
+		}
+	}
+
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	for i := 0; i < 50; i++ {
+		assert.Equal(t, i%2, solver.State.Vars[i].Value)
+	}
+	// each assignment only checks the (at most two) constraints on that
+	// variable, rather than all 49 constraints in the problem
+	assert.Less(t, evaluations, 400)
+}
+
+func TestConstraintsWithoutVars(t *testing.T) {
+	sum := func(variables *Variables[int]) bool {
+		a, b := variables.Find("A"), variables.Find("B")
+		return a.Empty || b.Empty || a.Value+b.Value == 4
+	}
+	for _, strategy := range []SearchStrategy{ChronologicalBacktracking, ConflictDirectedBackjumping, LimitedDiscrepancySearch} {
+		vars := Variables[int]{
+			NewVariable("A", IntRange(0, 5)),
+			NewVariable("B", IntRange(0, 5)),
+		}
+		// no Vars at all, and Vars that leave out B
+		constraints := Constraints[int]{
+			{ConstraintFunction: sum},
+			{Vars: VariableNames{"A"}, ConstraintFunction: func(variables *Variables[int]) bool {
+				a, b := variables.Find("A"), variables.Find("B")
+				return a.Empty || b.Empty || a.Value > b.Value
+			}},
+		}
+		solver := NewBackTrackingCSPSolver(vars, constraints)
+		solver.Options.Search = strategy
+		success, err := solver.Solve(context.TODO())
+		assert.Nil(t, err)
+		assert.True(t, success)
+		assert.True(t, constraints.AllSatisfied(&solver.State.Vars))
+		assert.Equal(t, 3, solver.State.Vars.Find("A").Value)
+		assert.Equal(t, 1, solver.State.Vars.Find("B").Value)
+	}
+}
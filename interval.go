@@ -0,0 +1,373 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"golang.org/x/exp/constraints"
+)
+
+var (
+	// ErrEmptyDomain returned when propagation removes every value from a domain
+	ErrEmptyDomain error = errors.New("domain reduced to empty set")
+)
+
+// gridTolerance fraction of a Step by which a value may miss the grid of an
+// interval and still count as on it, to allow for floating point error
+const gridTolerance = 1e-6
+
+// IntervalDomain domain stored only as its lower and upper bounds (inclusive),
+// along with an optional set of holes inside those bounds. Step is the
+// precision of the domain: the distance between two neighbouring values.
+// The values are Min, Min + Step, Min + 2*Step and so on up to Max, and any
+// other value is not in the domain. Integer intervals use a Step of 1.
+type IntervalDomain[T constraints.Integer | constraints.Float] struct {
+	Min  T
+	Max  T
+	Step T
+	// holes positions of the holes on the grid, counted in steps from base
+	holes map[int]struct{}
+	base  T
+}
+
+// NewIntervalDomain create an integer interval domain from min to max (inclusive)
+func NewIntervalDomain[T constraints.Integer](min T, max T) *IntervalDomain[T] {
+	return &IntervalDomain[T]{Min: min, Max: max, Step: 1}
+}
+
+// NewFloatIntervalDomain create a float interval domain from min to max (inclusive)
+// where values closer together than precision are not distinguished.
+func NewFloatIntervalDomain(min float64, max float64, precision float64) *IntervalDomain[float64] {
+	if precision <= 0 {
+		panic("Interval precision must be positive.")
+	}
+	return &IntervalDomain[float64]{Min: min, Max: max, Step: precision}
+}
+
+// Contains check if the value is on the grid within the bounds and not a hole
+func (interval *IntervalDomain[T]) Contains(value T) bool {
+	step, ok := interval.steps(interval.Min, value)
+	if !ok || step < 0 || step > interval.last() {
+		return false
+	}
+	return !interval.hole(value)
+}
+
+// Empty indicates if there are no values left in the interval
+func (interval *IntervalDomain[T]) Empty() bool {
+	return interval.Min > interval.Max
+}
+
+// Size number of values in the interval, excluding holes
+func (interval *IntervalDomain[T]) Size() int {
+	if interval.Empty() {
+		return 0
+	}
+	last := interval.last()
+	size := last + 1
+	for hole := range interval.holes {
+		step, _ := interval.steps(interval.Min, interval.base+T(hole)*interval.Step)
+		if step >= 0 && step <= last {
+			size--
+		}
+	}
+	return size
+}
+
+// SetMin raise the lower bound of the interval to the first value on the
+// grid at or above min. Returns true if the bound changed.
+func (interval *IntervalDomain[T]) SetMin(min T) bool {
+	if min <= interval.Min {
+		return false
+	}
+	return interval.raise(math.Ceil(interval.exactSteps(min) - gridTolerance))
+}
+
+// SetMinAbove raise the lower bound of the interval to the first value on
+// the grid strictly above bound, which need not be on the grid itself.
+// Returns true if the bound changed.
+func (interval *IntervalDomain[T]) SetMinAbove(bound T) bool {
+	if bound < interval.Min {
+		return false
+	}
+	return interval.raise(math.Floor(interval.exactSteps(bound)+gridTolerance) + 1)
+}
+
+// SetMax lower the upper bound of the interval to the last value on the
+// grid at or below max. Returns true if the bound changed.
+func (interval *IntervalDomain[T]) SetMax(max T) bool {
+	if max >= interval.Max {
+		return false
+	}
+	return interval.lower(math.Floor(interval.exactSteps(max) + gridTolerance))
+}
+
+// SetMaxBelow lower the upper bound of the interval to the last value on
+// the grid strictly below bound, which need not be on the grid itself.
+// Returns true if the bound changed.
+func (interval *IntervalDomain[T]) SetMaxBelow(bound T) bool {
+	if bound > interval.Max {
+		return false
+	}
+	return interval.lower(math.Ceil(interval.exactSteps(bound)-gridTolerance) - 1)
+}
+
+// exactSteps how many steps value is above Min, not rounded to the grid
+func (interval *IntervalDomain[T]) exactSteps(value T) float64 {
+	return (float64(value) - float64(interval.Min)) / float64(interval.Step)
+}
+
+// raise move Min up the given number of steps
+func (interval *IntervalDomain[T]) raise(steps float64) bool {
+	if steps <= 0 {
+		return false
+	}
+	interval.Min += T(steps) * interval.Step
+	interval.skipHoles()
+	return true
+}
+
+// lower move Max down to the given number of steps above Min, emptying the
+// interval if that is below Min
+func (interval *IntervalDomain[T]) lower(steps float64) bool {
+	if steps >= float64(interval.last()) {
+		return false
+	}
+	if steps < 0 {
+		// Min - Step could wrap around for unsigned types
+		interval.Max = interval.Min
+		interval.Min += interval.Step
+		return true
+	}
+	interval.Max = interval.Min + T(steps)*interval.Step
+	interval.skipHoles()
+	return true
+}
+
+// Remove a single value from the interval. Removing a bound shrinks the
+// interval, anything else is recorded as a hole. Returns true if the interval changed.
+func (interval *IntervalDomain[T]) Remove(value T) bool {
+	if !interval.Contains(value) {
+		return false
+	}
+	step, _ := interval.steps(interval.Min, value)
+	if step == 0 {
+		return interval.SetMinAbove(value)
+	}
+	if step == interval.last() {
+		return interval.SetMaxBelow(value)
+	}
+	if interval.holes == nil {
+		interval.holes, interval.base = make(map[int]struct{}), interval.Min
+	}
+	hole, _ := interval.steps(interval.base, value)
+	interval.holes[hole] = struct{}{}
+	return true
+}
+
+// Values materialize the interval as a Domain. Use this once bounds
+// propagation has narrowed the interval enough to search over it.
+func (interval *IntervalDomain[T]) Values() Domain[T] {
+	domain := make(Domain[T], 0, interval.Size())
+	if interval.Empty() {
+		return domain
+	}
+	for step := 0; step <= interval.last(); step++ {
+		value := interval.Min + T(step)*interval.Step
+		if !interval.hole(value) {
+			domain = append(domain, value)
+		}
+	}
+	return domain
+}
+
+// Copy return an independent copy of the interval
+func (interval *IntervalDomain[T]) Copy() *IntervalDomain[T] {
+	copied := &IntervalDomain[T]{Min: interval.Min, Max: interval.Max, Step: interval.Step, base: interval.base}
+	if len(interval.holes) > 0 {
+		copied.holes = make(map[int]struct{}, len(interval.holes))
+		for hole := range interval.holes {
+			copied.holes[hole] = struct{}{}
+		}
+	}
+	return copied
+}
+
+// steps number of steps from a value on the grid to another value, and
+// whether the other value is on the grid too
+func (interval *IntervalDomain[T]) steps(from T, value T) (int, bool) {
+	exact := (float64(value) - float64(from)) / float64(interval.Step)
+	rounded := math.Round(exact)
+	return int(rounded), math.Abs(exact-rounded) <= gridTolerance
+}
+
+// last number of steps from Min to the last value on the grid within Max
+func (interval *IntervalDomain[T]) last() int {
+	return int(math.Floor((float64(interval.Max)-float64(interval.Min))/float64(interval.Step) + gridTolerance))
+}
+
+// hole check if a value on the grid has been removed as a hole
+func (interval *IntervalDomain[T]) hole(value T) bool {
+	if len(interval.holes) == 0 {
+		return false
+	}
+	hole, _ := interval.steps(interval.base, value)
+	_, ok := interval.holes[hole]
+	return ok
+}
+
+// skipHoles move the bounds inward past any holes sitting on them
+func (interval *IntervalDomain[T]) skipHoles() {
+	for !interval.Empty() && interval.hole(interval.Min) {
+		hole, _ := interval.steps(interval.base, interval.Min)
+		delete(interval.holes, hole)
+		interval.Min += interval.Step
+	}
+	for !interval.Empty() {
+		top := interval.Min + T(interval.last())*interval.Step
+		if !interval.hole(top) {
+			break
+		}
+		hole, _ := interval.steps(interval.base, top)
+		delete(interval.holes, hole)
+		interval.Max = top - interval.Step
+	}
+}
+
+// IntervalDomains collection of interval domains keyed by variable name
+type IntervalDomains[T constraints.Integer | constraints.Float] map[VariableName]*IntervalDomain[T]
+
+// BoundsFunction narrows the bounds of the given domains. Returns true if any bound changed.
+type BoundsFunction[T constraints.Integer | constraints.Float] func(domains IntervalDomains[T]) bool
+
+// BoundsConstraint constraint enforced only on the bounds of interval domains
+type BoundsConstraint[T constraints.Integer | constraints.Float] struct {
+	Vars           VariableNames
+	BoundsFunction BoundsFunction[T]
+}
+
+// BoundsConstraints collection type for BoundsConstraint
+type BoundsConstraints[T constraints.Integer | constraints.Float] []BoundsConstraint[T]
+
+// PropagateBounds narrow the interval domains until every bounds constraint
+// is at a fixpoint. Returns ErrEmptyDomain if any interval becomes empty.
+// If ctx finishes first, propagation stops before the next constraint and
+// ErrExecutionCanceled is returned.
+func (domains IntervalDomains[T]) PropagateBounds(ctx context.Context, constraints BoundsConstraints[T]) error {
+	result, err := RunWithContext(ctx, func() error {
+		return domains.propagateBounds(ctx.Done(), constraints)
+	})
+	if err != nil {
+		return err
+	}
+	return *result
+}
+
+func (domains IntervalDomains[T]) propagateBounds(done <-chan struct{}, constraints BoundsConstraints[T]) error {
+	// queue of constraint indices, same approach as AC-3
+	queue := make([]int, 0, len(constraints))
+	queued := make([]bool, len(constraints))
+	for i := range constraints {
+		queue = append(queue, i)
+		queued[i] = true
+	}
+	for len(queue) > 0 {
+		if finished(done) {
+			return ErrExecutionCanceled
+		}
+		index := queue[0]
+		queue = queue[1:]
+		queued[index] = false
+		constraint := constraints[index]
+		if !constraint.BoundsFunction(domains) {
+			continue
+		}
+		for _, name := range constraint.Vars {
+			if domains[name].Empty() {
+				return ErrEmptyDomain
+			}
+		}
+		// revisit every other constraint sharing a variable with this one
+		for index2, constraint2 := range constraints {
+			if index2 == index || queued[index2] {
+				continue
+			}
+			for _, name := range constraint.Vars {
+				if constraint2.Vars.Contains(name) {
+					queue = append(queue, index2)
+					queued[index2] = true
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// BoundsLessThan bounds constraint generator that enforces var1 < var2
+func BoundsLessThan[T constraints.Integer | constraints.Float](var1 VariableName, var2 VariableName) BoundsConstraint[T] {
+	return BoundsConstraint[T]{Vars: VariableNames{var1, var2}, BoundsFunction: func(domains IntervalDomains[T]) bool {
+		x, y := domains[var1], domains[var2]
+		changedX := x.SetMaxBelow(y.Max)
+		changedY := y.SetMinAbove(x.Min)
+		return changedX || changedY
+	}}
+}
+
+// BoundsLessThanOrEqualTo bounds constraint generator that enforces var1 <= var2
+func BoundsLessThanOrEqualTo[T constraints.Integer | constraints.Float](var1 VariableName, var2 VariableName) BoundsConstraint[T] {
+	return BoundsConstraint[T]{Vars: VariableNames{var1, var2}, BoundsFunction: func(domains IntervalDomains[T]) bool {
+		x, y := domains[var1], domains[var2]
+		changedX := x.SetMax(y.Max)
+		changedY := y.SetMin(x.Min)
+		return changedX || changedY
+	}}
+}
+
+// BoundsEquals bounds constraint generator that enforces var1 == var2
+func BoundsEquals[T constraints.Integer | constraints.Float](var1 VariableName, var2 VariableName) BoundsConstraint[T] {
+	return BoundsOffset[T](var1, var2, 0)
+}
+
+// BoundsOffset bounds constraint generator that enforces var1 + offset == var2
+func BoundsOffset[T constraints.Integer | constraints.Float](var1 VariableName, var2 VariableName, offset T) BoundsConstraint[T] {
+	return BoundsConstraint[T]{Vars: VariableNames{var1, var2}, BoundsFunction: func(domains IntervalDomains[T]) bool {
+		x, y := domains[var1], domains[var2]
+		changed := false
+		changed = x.SetMin(y.Min-offset) || changed
+		changed = x.SetMax(y.Max-offset) || changed
+		changed = y.SetMin(x.Min+offset) || changed
+		changed = y.SetMax(x.Max+offset) || changed
+		return changed
+	}}
+}
+
+// BoundsSum bounds constraint generator that enforces var1 + var2 == total
+func BoundsSum[T constraints.Integer | constraints.Float](var1 VariableName, var2 VariableName, total VariableName) BoundsConstraint[T] {
+	return BoundsConstraint[T]{Vars: VariableNames{var1, var2, total}, BoundsFunction: func(domains IntervalDomains[T]) bool {
+		x, y, z := domains[var1], domains[var2], domains[total]
+		changed := false
+		changed = z.SetMin(x.Min+y.Min) || changed
+		changed = z.SetMax(x.Max+y.Max) || changed
+		changed = x.SetMin(z.Min-y.Max) || changed
+		changed = x.SetMax(z.Max-y.Min) || changed
+		changed = y.SetMin(z.Min-x.Max) || changed
+		changed = y.SetMax(z.Max-x.Min) || changed
+		return changed
+	}}
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntervalDomain(t *testing.T) {
+	interval := NewIntervalDomain(0, 1_000_000)
+	assert.Equal(t, 1_000_001, interval.Size())
+
+	// removing a bound shrinks the interval, anything else is a hole
+	assert.True(t, interval.Remove(0))
+	assert.True(t, interval.Remove(500))
+	assert.False(t, interval.Remove(500))
+	assert.Equal(t, 1, interval.Min)
+	assert.False(t, interval.Contains(500))
+	assert.Equal(t, 999_999, interval.Size())
+
+	// bounds skip over holes
+	interval.Remove(2)
+	interval.SetMax(3)
+	assert.True(t, interval.Remove(1))
+	assert.Equal(t, 3, interval.Min)
+	assert.Equal(t, Domain[int]{3}, interval.Values())
+
+	floats := NewFloatIntervalDomain(0, 1, 0.25)
+	assert.Equal(t, 5, floats.Size())
+	assert.Equal(t, Domain[float64]{0, 0.25, 0.5, 0.75, 1}, floats.Values())
+
+	// only values on the grid are in a float interval
+	floats = NewFloatIntervalDomain(0, 1, 0.1)
+	assert.Equal(t, 11, floats.Size())
+	assert.True(t, floats.Contains(0.3))
+	assert.True(t, floats.Contains(0.1+0.2))
+	assert.False(t, floats.Contains(0.15))
+	assert.False(t, floats.Remove(0.15))
+	assert.True(t, floats.Remove(0.1+0.2))
+	assert.False(t, floats.Contains(0.3))
+	assert.Equal(t, len(floats.Values()), floats.Size())
+	// bounds are moved onto the grid
+	assert.True(t, floats.SetMin(0.05))
+	assert.InDelta(t, 0.1, floats.Min, 1e-9)
+	assert.True(t, floats.SetMax(0.85))
+	assert.InDelta(t, 0.8, floats.Max, 1e-9)
+	assert.False(t, floats.SetMax(0.89))
+	assert.Equal(t, 7, floats.Size())
+	assert.Equal(t, len(floats.Values()), floats.Size())
+	// a hole on the new bound is skipped, as for integers
+	assert.True(t, floats.SetMin(0.25))
+	assert.InDelta(t, 0.4, floats.Min, 1e-9)
+	assert.Equal(t, 5, floats.Size())
+	assert.Equal(t, len(floats.Values()), floats.Size())
+}
+
+func TestPropagateBounds(t *testing.T) {
+	// A < B, B + C = 10, C >= 7 over huge intervals
+	domains := IntervalDomains[int]{
+		"A": NewIntervalDomain(0, 1_000_000),
+		"B": NewIntervalDomain(0, 1_000_000),
+		"C": NewIntervalDomain(7, 1_000_000),
+		"T": NewIntervalDomain(10, 10),
+	}
+	constraints := BoundsConstraints[int]{
+		BoundsLessThan[int]("A", "B"),
+		BoundsSum[int]("B", "C", "T"),
+	}
+	err := domains.PropagateBounds(context.TODO(), constraints)
+	assert.Nil(t, err)
+
+	assert.Equal(t, Domain[int]{0, 1, 2}, domains["A"].Values())
+	assert.Equal(t, Domain[int]{1, 2, 3}, domains["B"].Values())
+	assert.Equal(t, Domain[int]{7, 8, 9}, domains["C"].Values())
+
+	// contradictory bounds empty the domain
+	domains["D"] = NewIntervalDomain(5, 6)
+	constraints = append(constraints, BoundsLessThan[int]("D", "A"))
+	err = domains.PropagateBounds(context.TODO(), constraints)
+	assert.Equal(t, ErrEmptyDomain, err)
+
+	// float intervals propagate with their precision
+	floats := IntervalDomains[float64]{
+		"X": NewFloatIntervalDomain(0, 10, 0.5),
+		"Y": NewFloatIntervalDomain(0, 2, 0.5),
+	}
+	err = floats.PropagateBounds(context.TODO(), BoundsConstraints[float64]{BoundsLessThan[float64]("X", "Y")})
+	assert.Nil(t, err)
+	assert.Equal(t, 1.5, floats["X"].Max)
+	assert.Equal(t, 0.5, floats["Y"].Min)
+}
+
+func TestPropagateBoundsMixedGrids(t *testing.T) {
+	// X and Y share no grid values, so X < Y must not subtract either step
+	floats := IntervalDomains[float64]{
+		"X": &IntervalDomain[float64]{Min: 0.05, Max: 0.95, Step: 0.1},
+		"Y": NewFloatIntervalDomain(0, 1, 0.5),
+	}
+	err := floats.PropagateBounds(context.TODO(), BoundsConstraints[float64]{BoundsLessThan[float64]("X", "Y")})
+	assert.Nil(t, err)
+	assert.InDelta(t, 0.95, floats["X"].Max, 1e-9)
+	assert.Equal(t, Domain[float64]{0.5, 1}, floats["Y"].Values())
+
+	// strict bounds off the grid
+	interval := NewFloatIntervalDomain(0, 1, 0.25)
+	assert.False(t, interval.SetMaxBelow(1.1))
+	assert.True(t, interval.SetMaxBelow(0.6))
+	assert.Equal(t, 0.5, interval.Max)
+	assert.True(t, interval.SetMaxBelow(0.5))
+	assert.Equal(t, 0.25, interval.Max)
+	assert.True(t, interval.SetMinAbove(0))
+	assert.Equal(t, 0.25, interval.Min)
+	assert.False(t, interval.SetMinAbove(-1))
+
+	// unsigned bounds empty the interval rather than wrap around
+	unsigned := IntervalDomains[uint]{
+		"A": NewIntervalDomain[uint](0, 5),
+		"B": NewIntervalDomain[uint](0, 0),
+	}
+	err = unsigned.PropagateBounds(context.TODO(), BoundsConstraints[uint]{BoundsLessThan[uint]("A", "B")})
+	assert.Equal(t, ErrEmptyDomain, err)
+	single := NewIntervalDomain[uint](0, 0)
+	assert.True(t, single.Remove(0))
+	assert.True(t, single.Empty())
+}
+
+func TestPropagateBoundsCanceled(t *testing.T) {
+	domains := IntervalDomains[int]{
+		"A": NewIntervalDomain(0, 1_000_000),
+		"B": NewIntervalDomain(0, 1_000_000),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := domains.propagateBounds(ctx.Done(), BoundsConstraints[int]{BoundsLessThan[int]("A", "B")})
+	assert.Equal(t, ErrExecutionCanceled, err)
+	assert.Equal(t, 1_000_000, domains["A"].Max)
+}
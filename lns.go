@@ -0,0 +1,262 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+)
+
+var (
+	// ErrInvalidIncumbent the LNSSolver Incumbent is not a solution of the problem
+	ErrInvalidIncumbent error = errors.New("incumbent is not a solution")
+)
+
+// DefaultLNSFailLimit number of failures allowed per neighborhood when LNSSolver.FailLimit is not set
+const DefaultLNSFailLimit = 100
+
+// NeighborhoodFunction chooses up to size variables to relax, given the
+// incumbent solution in state. Variables that were assigned before solving
+// began are never relaxed, even if they are returned.
+type NeighborhoodFunction[T comparable] func(state *CSPState[T], size int, random *rand.Rand) VariableNames
+
+// LNSSolver large neighborhood search. Starting from a solution (the
+// incumbent), it repeatedly relaxes a few variables, keeps the rest fixed
+// at their incumbent values, and searches the neighborhood with the
+// backtracking search for a solution with a lower Objective. Each
+// neighborhood is only searched until FailLimit failures, so many can be
+// tried, and the search goes on until ctx is done or MaxIterations is
+// reached.
+type LNSSolver[T comparable] struct {
+	State CSPState[T]
+	// Options options for the backtracking search of each neighborhood
+	Options SolverOptions
+	// Objective cost of a complete assignment, to be minimised. If nil,
+	// Solve stops at the first solution.
+	Objective func(variables *Variables[T]) float64
+	// Incumbent optional solution to start from, with the variables in the
+	// same order as State. It must assign every variable a value from its
+	// domain, agree with those already assigned in State, and satisfy the
+	// hard constraints. If not given, the first solution found by the
+	// backtracking search is used.
+	Incumbent Variables[T]
+	// Neighborhood chooses the variables to relax. Defaults to RandomNeighborhood.
+	Neighborhood NeighborhoodFunction[T]
+	// NeighborhoodSize number of variables to relax. Defaults to a fifth of
+	// the unassigned variables.
+	NeighborhoodSize int
+	// FailLimit failures allowed when searching each neighborhood (nodes, if
+	// Options.RestartLimit is NodeLimit). Defaults to DefaultLNSFailLimit.
+	FailLimit int
+	// MaxIterations stop after searching this many neighborhoods. 0 keeps
+	// going until ctx is done.
+	MaxIterations int
+	// Seed seed for choosing neighborhoods
+	Seed int64
+	// Cost objective value of the incumbent
+	Cost float64
+	// Iterations number of neighborhoods searched by the last call to Solve
+	Iterations int
+	// search backtracking solver reused for every neighborhood, so that
+	// heuristic weights carry over, along with any nogoods learned while
+	// finding the first solution. Neighborhoods are searched
+	// chronologically, so they don't learn any more.
+	search *BackTrackingCSPSolver[T]
+}
+
+// NewLNSSolver create a large neighborhood search solver minimising objective
+func NewLNSSolver[T comparable](vars Variables[T], constraints Constraints[T], objective func(variables *Variables[T]) float64) LNSSolver[T] {
+	return LNSSolver[T]{State: CSPState[T]{Vars: vars, Constraints: constraints, Propagations: []Propagation[T]{}}, Objective: objective}
+}
+
+// RandomNeighborhood relax variables chosen at random
+func RandomNeighborhood[T comparable](state *CSPState[T], size int, random *rand.Rand) VariableNames {
+	names := make(VariableNames, 0, size)
+	for _, i := range random.Perm(len(state.Vars)) {
+		if len(names) == size {
+			break
+		}
+		names = append(names, state.Vars[i].Name)
+	}
+	return names
+}
+
+// ConstraintNeighborhood relax a group of variables that are connected by
+// constraints, starting from one chosen at random. Changing variables that
+// constrain each other together gives the search more room to move.
+func ConstraintNeighborhood[T comparable](state *CSPState[T], size int, random *rand.Rand) VariableNames {
+	names := make(VariableNames, 0, size)
+	chosen := make([]bool, len(state.Vars))
+	order := random.Perm(len(state.Vars))
+	queue := make([]int, 0)
+	for len(names) < size && len(names) < len(state.Vars) {
+		if len(queue) == 0 {
+			// start again from a random variable not yet chosen
+			for _, i := range order {
+				if !chosen[i] {
+					chosen[i] = true
+					queue = append(queue, i)
+					break
+				}
+			}
+		}
+		i := queue[0]
+		queue = queue[1:]
+		names = append(names, state.Vars[i].Name)
+		incident := state.index.incident[i]
+		for _, k := range random.Perm(len(incident)) {
+			for _, j := range state.index.constraints[incident[k]] {
+				if !chosen[j] {
+					chosen[j] = true
+					queue = append(queue, j)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// Solve find a solution, then improve it until ctx is done or MaxIterations
+// is reached. The best solution found is left in State. As with the other
+// optimising solvers, running out of time is not an error once a solution
+// has been found. Returns false if there is no solution to start from, and
+// ErrInvalidIncumbent if the Incumbent given isn't one.
+func (solver *LNSSolver[T]) Solve(ctx context.Context) (bool, error) {
+	solver.Iterations = 0
+	if solver.search == nil {
+		solver.search = &BackTrackingCSPSolver[T]{}
+	}
+	solver.search.Options = solver.Options
+	original := solver.State.Vars.Copy()
+	free := make([]bool, len(original))
+	for i := range original {
+		free[i] = original[i].Empty
+	}
+
+	incumbent := solver.Incumbent
+	if incumbent != nil && !solver.State.solvedBy(incumbent) {
+		return false, ErrInvalidIncumbent
+	}
+	if incumbent == nil {
+		solver.search.State = solver.State.Copy()
+		solved, err := solver.search.Solve(ctx)
+		if !solved {
+			return false, err
+		}
+		incumbent = solver.search.State.Vars
+	}
+	incumbent = incumbent.Copy()
+	if solver.Objective == nil {
+		solver.State.Vars = incumbent
+		return true, nil
+	}
+	solver.Cost = solver.Objective(&incumbent)
+
+	state := solver.State.Copy()
+	state.compile()
+	neighborhood := solver.Neighborhood
+	if neighborhood == nil {
+		neighborhood = RandomNeighborhood[T]
+	}
+	size := solver.NeighborhoodSize
+	if size <= 0 {
+		size = max(1, original.Unassigned()/5)
+	}
+	failLimit := solver.FailLimit
+	if failLimit <= 0 {
+		failLimit = DefaultLNSFailLimit
+	}
+	random := rand.New(rand.NewSource(solver.Seed))
+
+	done := ctx.Done()
+	for solver.MaxIterations <= 0 || solver.Iterations < solver.MaxIterations {
+		if finished(done) {
+			break
+		}
+		solver.Iterations++
+
+		// fix everything but the neighborhood at its incumbent value
+		state.Vars = incumbent.Copy()
+		relaxed := make([]bool, len(state.Vars))
+		for _, name := range neighborhood(&state, size, random) {
+			if i, ok := state.index.vars[name]; ok && free[i] {
+				relaxed[i] = true
+			}
+		}
+		for i := range state.Vars {
+			if relaxed[i] {
+				state.Vars[i] = original[i]
+				state.Vars[i].Domain = append(Domain[T]{}, original[i].Domain...)
+			}
+		}
+
+		solver.search.State = state
+		if cost, improved := solver.improve(ctx, failLimit); improved {
+			incumbent, solver.Cost = solver.search.State.Vars, cost
+			if state.index.debug {
+				state.trace("improve", "iteration", solver.Iterations, "cost", cost)
+			}
+		}
+	}
+	solver.State.Vars = incumbent
+	return true, nil
+}
+
+// solvedBy whether vars is a solution: the same variables, each assigned a
+// value from its domain, keeping any already assigned in state, and
+// satisfying every hard constraint
+func (state *CSPState[T]) solvedBy(vars Variables[T]) bool {
+	if len(vars) != len(state.Vars) {
+		return false
+	}
+	candidate := state.Copy()
+	for i := range candidate.Vars {
+		variable := &candidate.Vars[i]
+		if vars[i].Name != variable.Name || vars[i].Empty {
+			return false
+		}
+		if !variable.Empty && variable.Value != vars[i].Value || !variable.Domain.Contains(vars[i].Value) {
+			return false
+		}
+		variable.SetValue(vars[i].Value)
+	}
+	candidate.compile()
+	return candidate.allSatisfied()
+}
+
+// improve search the neighborhood set up in the backtracking solver for the
+// solution with the lowest cost, until the fail limit is reached. If one
+// beats the incumbent, it is left in the backtracking solver's State.
+func (solver *LNSSolver[T]) improve(ctx context.Context, failLimit int) (float64, bool) {
+	s := newSearch(ctx, solver.search)
+	if !s.state.allSatisfied() {
+		return 0, false
+	}
+	s.cutoff = failLimit
+	best, improved := solver.Cost, false
+	var solution Variables[T]
+	s.onSolution = func() bool {
+		if cost := solver.Objective(&s.state.Vars); cost < best {
+			best, improved, solution = cost, true, s.state.Vars.Copy()
+		}
+		return false
+	}
+	s.searchOnce()
+	if improved {
+		solver.search.State.Vars = solution
+	}
+	return best, improved
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLargeNeighborhoodSearch(t *testing.T) {
+	// give eight jobs different slots. Job i costs i+1 per slot it waits, so
+	// the best schedule runs the last jobs first.
+	vars := make(Variables[int], 0)
+	names := make(VariableNames, 0)
+	for i := 0; i < 8; i++ {
+		name := VariableName(fmt.Sprintf("J%d", i))
+		vars = append(vars, NewVariable(name, IntRange(0, 8)))
+		names = append(names, name)
+	}
+	constraints := AllUnique[int](names...)
+	cost := func(variables *Variables[int]) float64 {
+		total := 0
+		for i, variable := range *variables {
+			total += (i + 1) * variable.Value
+		}
+		return float64(total)
+	}
+
+	// the optimum gives job i slot 7-i
+	optimum := 0.0
+	for i := 0; i < 8; i++ {
+		optimum += float64((i + 1) * (7 - i))
+	}
+
+	neighborhoods := map[string]NeighborhoodFunction[int]{
+		"random":     RandomNeighborhood[int],
+		"constraint": ConstraintNeighborhood[int],
+		"custom": func(state *CSPState[int], size int, random *rand.Rand) VariableNames {
+			// always swap a pair of neighbouring jobs
+			i := random.Intn(len(state.Vars) - 1)
+			return VariableNames{state.Vars[i].Name, state.Vars[i+1].Name}
+		},
+	}
+	for name, neighborhood := range neighborhoods {
+		solver := NewLNSSolver(vars.Copy(), constraints, cost)
+		solver.Neighborhood = neighborhood
+		solver.NeighborhoodSize = 3
+		solver.MaxIterations = 500
+		success, err := solver.Solve(context.TODO())
+		assert.Nil(t, err, name)
+		assert.True(t, success, name)
+		assert.Equal(t, 500, solver.Iterations, name)
+		assert.Equal(t, optimum, solver.Cost, name)
+		assert.Equal(t, optimum, cost(&solver.State.Vars), name)
+		assert.True(t, constraints.AllSatisfied(&solver.State.Vars), name)
+	}
+
+	// J0 is fixed, so can never be relaxed
+	vars.SetValue("J0", 3)
+	solver := NewLNSSolver(vars, constraints, cost)
+	incumbent := vars.Copy()
+	for i := 1; i < 8; i++ {
+		value := i
+		if i <= 3 {
+			value = i - 1
+		}
+		incumbent[i].SetValue(value)
+	}
+	solver.Incumbent = incumbent
+	solver.NeighborhoodSize = 4
+	solver.MaxIterations = 300
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.Less(t, solver.Cost, cost(&incumbent))
+	assert.Equal(t, 3, solver.State.Vars.Find("J0").Value)
+	assert.True(t, constraints.AllSatisfied(&solver.State.Vars))
+
+	// a deadline stops the search with the incumbent
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	solver = NewLNSSolver(vars, constraints, cost)
+	solver.Incumbent = incumbent
+	success, err = solver.Solve(ctx)
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.Equal(t, 0, solver.Iterations)
+	assert.Equal(t, cost(&incumbent), solver.Cost)
+
+	// an incumbent has to be a solution that keeps J0 where it is
+	invalid := incumbent.Copy()
+	invalid.SetValue("J1", invalid.Find("J2").Value)
+	moved := incumbent.Copy()
+	moved.SetValue("J0", 4)
+	moved.SetValue("J4", 3)
+	partial := incumbent.Copy()
+	partial[7].Unset()
+	for _, bad := range []Variables[int]{invalid, moved, partial, incumbent[:7]} {
+		solver = NewLNSSolver(vars, constraints, cost)
+		solver.Incumbent = bad
+		success, err = solver.Solve(context.TODO())
+		assert.ErrorIs(t, err, ErrInvalidIncumbent)
+		assert.False(t, success)
+	}
+}
@@ -25,18 +25,25 @@ func (state *CSPState[T]) SimplifyPreAssignment(ctx context.Context) error {
 }
 
 func (state *CSPState[T]) simplify() {
+	state.compile()
+	state.keepUnpruned()
+	state.provenance = nil
 
 	for _, variable := range state.Vars {
 		if !variable.Empty { // assigned to
 			// get all constraints associated with this variable
 			assignedConstraints := state.Constraints.FilterByName(variable.Name)
 			for _, assignedConstraint := range assignedConstraints {
+				if assignedConstraint.Soft {
+					// soft constraints may be broken, so can't rule anything out
+					continue
+				}
 				for _, constraintVarName := range assignedConstraint.Vars {
 					// don't compare the variable in question to itself
 					if constraintVarName == variable.Name {
 						continue
 					}
-					constrainedVariable := state.Vars.Find(constraintVarName)
+					constrainedVariable := state.find(constraintVarName)
 					// continue if this is one of the variables already assigned to
 					if !constrainedVariable.Empty {
 						continue
@@ -45,19 +52,23 @@ func (state *CSPState[T]) simplify() {
 					// for the unassigned variable we're comparing too
 					if constrainedVariable.Domain.Contains(variable.Value) {
 						resultBefore := assignedConstraint.ConstraintFunction(&state.Vars)
-						state.Vars.SetValue(constrainedVariable.Name, variable.Value)
+						constrainedVariable.SetValue(variable.Value)
 						resultAfter := assignedConstraint.ConstraintFunction(&state.Vars)
-						state.Vars.Unset(constrainedVariable.Name)
+						constrainedVariable.Unset()
 						if resultBefore && !resultAfter {
 							// safe to assume that variable and constrainedVariable
 							// cannot both have this value. Remove this value from
 							// the domain of constrainedVariable
 							restrictedDomain := constrainedVariable.Domain.Remove(variable.Value)
-							state.Vars.SetDomain(constrainedVariable.Name, restrictedDomain)
+							constrainedVariable.SetDomain(restrictedDomain)
+							state.recordPruning(Pruning[T]{Variable: constrainedVariable.Name, Value: variable.Value, Constraint: assignedConstraint,
+								Assignments: []VariableAssignment[T]{{variable.Name, variable.Value}}})
+							state.trace("prune", "variable", constrainedVariable.Name, "value", variable.Value, "cause", variable.Name)
 							// if domain has only one value, set the value of the variable to
 							// avoid further complexity
 							if len(restrictedDomain) == 1 {
-								state.Vars.SetValue(constrainedVariable.Name, restrictedDomain[0])
+								constrainedVariable.SetValue(restrictedDomain[0])
+								state.unpruned.derived[constrainedVariable.Name] = restrictedDomain[0]
 							}
 						}
 					}
@@ -72,7 +83,9 @@ func (state *CSPState[T]) simplify() {
 // https://en.wikipedia.org/wiki/AC-3_algorithm
 func (state *CSPState[T]) MakeArcConsistent(ctx context.Context) error {
 	_, err := RunWithContext(ctx, func() bool {
-		state.arcConsistency()
+		if c, _ := state.arcConsistency(ctx.Done(), &SolveResult{}); c >= 0 {
+			panic(fmt.Sprintf("Domain reduced to empty slice for constraint %v", state.Constraints[c]))
+		}
 		return true
 	})
 	if err != nil {
@@ -82,7 +95,14 @@ func (state *CSPState[T]) MakeArcConsistent(ctx context.Context) error {
 	return nil
 }
 
-func (state *CSPState[T]) arcConsistency() {
+// arcConsistency enforce arc consistency, counting the arcs revised and
+// values removed in result. Returns the position of the constraint that
+// emptied a domain, or -1 if none did, and ErrExecutionCanceled if done is
+// closed before it finishes.
+func (state *CSPState[T]) arcConsistency(done <-chan struct{}, result *SolveResult) (int, error) {
+	state.compile()
+	state.keepUnpruned()
+	state.provenance = nil
 	// create queue of indices and fill it with constraints
 	queue := make([]int, 0)
 	for i := range state.Constraints {
@@ -90,21 +110,28 @@ func (state *CSPState[T]) arcConsistency() {
 	}
 	// loop until the queue is empty
 	for len(queue) > 0 {
+		if finished(done) {
+			return -1, ErrExecutionCanceled
+		}
 		// pop first item off of queue
 		index := queue[0]
 		queue = queue[1:]
 		constraint := state.Constraints[index]
-		// only consider binary constraints
-		if len(constraint.Vars) == 2 {
+		// only consider binary hard constraints
+		if len(constraint.Vars) == 2 && !constraint.Soft {
 			// must be arc consistent both ways
+			before1, before2 := state.find(constraint.Vars[0]).possibilities(), state.find(constraint.Vars[1]).possibilities()
 			change1, domain1 := arcReduce(constraint.Vars[0], constraint.Vars[1], constraint, state)
 			change2, domain2 := arcReduce(constraint.Vars[1], constraint.Vars[0], constraint, state)
+			result.Propagations += 2
 
 			if change1 {
 				if len(domain1) == 0 {
-					panic(fmt.Sprintf("Domain reduced to empty slice for constraint %v", constraint))
+					return index, nil
 				}
-				state.Vars.SetDomain(constraint.Vars[0], domain1)
+				result.DomainRemovals += before1 - len(domain1)
+				state.trace("prune", "variable", constraint.Vars[0], "domain", domain1, "constraint", constraint.Vars)
+				state.find(constraint.Vars[0]).SetDomain(domain1)
 				// add all neighbors of X excluding Y
 				for index2, constraint2 := range state.Constraints {
 					if constraint2.Vars.Contains(constraint.Vars[0]) && !constraint2.Vars.Contains(constraint.Vars[1]) {
@@ -115,9 +142,11 @@ func (state *CSPState[T]) arcConsistency() {
 
 			if change2 {
 				if len(domain2) == 0 {
-					panic(fmt.Sprintf("Domain reduced to empty slice for constraint %v", constraint))
+					return index, nil
 				}
-				state.Vars.SetDomain(constraint.Vars[1], domain2)
+				result.DomainRemovals += before2 - len(domain2)
+				state.trace("prune", "variable", constraint.Vars[1], "domain", domain2, "constraint", constraint.Vars)
+				state.find(constraint.Vars[1]).SetDomain(domain2)
 				// add all neighbors of X excluding Y
 				for index2, constraint2 := range state.Constraints {
 					if constraint2.Vars.Contains(constraint.Vars[1]) && !constraint2.Vars.Contains(constraint.Vars[0]) {
@@ -127,14 +156,15 @@ func (state *CSPState[T]) arcConsistency() {
 			}
 		}
 	}
+	return -1, nil
 }
 
 // arcReduce reduce the domain of both vars on a binary constraint using
 // arc consistency
 func arcReduce[T comparable](nameX, nameY VariableName, constraint Constraint[T], state *CSPState[T]) (bool, Domain[T]) {
 	var modifiedDomain Domain[T]
-	X := state.Vars.Find(nameX)
-	Y := state.Vars.Find(nameY)
+	X := state.find(nameX)
+	Y := state.find(nameY)
 	// if X is already assigned to, domain of X is simply the value of X
 	var dxValues []T // values of X
 	if !X.Empty {
@@ -166,9 +196,37 @@ func arcReduce[T comparable](nameX, nameY VariableName, constraint Constraint[T]
 		if !foundvy { // no corresponding vy for vx
 			modifiedDomain = modifiedDomain.Remove(vx)
 			change = true
+			state.recordPruning(arcPruning(X, vx, Y, constraint, state))
 		}
 	}
 	return change, modifiedDomain
 }
 
+// arcPruning the reason vx was removed from the domain of X: either Y is
+// assigned a value that rules it out, or every value of Y that allowed it
+// has been removed already
+func arcPruning[T comparable](X *Variable[T], vx T, Y *Variable[T], constraint Constraint[T], state *CSPState[T]) Pruning[T] {
+	pruning := Pruning[T]{Variable: X.Name, Value: vx, Constraint: constraint}
+	if !Y.Empty {
+		pruning.Assignments = []VariableAssignment[T]{{Y.Name, Y.Value}}
+		return pruning
+	}
+	for _, vy := range state.removedValues(Y.Name) {
+		tempVars := Variables[T]{Variable[T]{X.Name, vx, X.Domain, false}, Variable[T]{Y.Name, vy, Y.Domain, false}}
+		if constraint.ConstraintFunction(&tempVars) {
+			pruning.Removals = append(pruning.Removals, VariableAssignment[T]{Y.Name, vy})
+		}
+	}
+	return pruning
+}
+
 // todo: add support here for Node consistency
+
+// possibilities number of values the variable could take: 1 if it is
+// assigned, or else the size of its domain
+func (variable *Variable[T]) possibilities() int {
+	if !variable.Empty {
+		return 1
+	}
+	return len(variable.Domain)
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugLogging(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(1, 4)),
+		NewVariable("B", IntRange(1, 4)),
+	}
+	constraints := Constraints[int]{
+		GreaterThan[int]("A", "B"),
+	}
+	propagations := Propagations[int]{
+		// if A is assigned a value, B can't take that value either
+		Propagation[int]{Vars: VariableNames{"A"}, PropagationFunction: func(assignment VariableAssignment[int], variables *Variables[int]) []DomainRemoval[int] {
+			return []DomainRemoval[int]{{VariableName: "B", Value: assignment.Value}}
+		}},
+	}
+
+	var buffer bytes.Buffer
+	solver := NewBackTrackingCSPSolverWithPropagation(vars, constraints, propagations)
+	solver.State.Logger = slog.New(slog.NewTextHandler(&buffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+
+	output := buffer.String()
+	assert.Contains(t, output, "msg=assign variable=A value=1")
+	assert.Contains(t, output, "msg=prune variable=B value=1 cause=A")
+	assert.Contains(t, output, "msg=backtrack variable=B")
+
+	// nothing is logged above debug level
+	buffer.Reset()
+	solver = NewBackTrackingCSPSolverWithPropagation(vars, constraints, propagations)
+	solver.State.Logger = slog.New(slog.NewTextHandler(&buffer, nil))
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.Empty(t, buffer.String())
+}
@@ -0,0 +1,307 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"math"
+	"math/rand"
+)
+
+const (
+	// DefaultInitialTemperature starting temperature when AnnealingOptions.InitialTemperature is not set
+	DefaultInitialTemperature = 1.0
+	// DefaultFinalTemperature temperature LinearCooling ends at when AnnealingOptions.FinalTemperature is not set
+	DefaultFinalTemperature = 0.001
+	// DefaultCoolingRate factor GeometricCooling multiplies the temperature by each step
+	DefaultCoolingRate = 0.999
+	// DefaultTabuTenure number of steps a move stays tabu when TabuSearchOptions.TabuTenure is not set
+	DefaultTabuTenure = 10
+)
+
+// CoolingSchedule how the temperature of simulated annealing falls over time
+type CoolingSchedule int
+
+const (
+	// GeometricCooling multiply the temperature by CoolingRate every step. This is the default.
+	GeometricCooling CoolingSchedule = iota
+	// LinearCooling lower the temperature in equal steps from
+	// InitialTemperature to FinalTemperature over MaxSteps
+	LinearCooling
+	// LogarithmicCooling divide InitialTemperature by 1 + log(1 + step),
+	// which cools very slowly
+	LogarithmicCooling
+)
+
+// AnnealingSolver simulated annealing for over-constrained problems. It
+// minimises the total Weight of the violated constraints, starting from a
+// random assignment and trying random changes to variables in violated
+// constraints. Changes that make things worse are sometimes accepted, less
+// often the worse they are and the lower the temperature, which lets the
+// search climb out of local minima early on.
+type AnnealingSolver[T comparable] struct {
+	State   CSPState[T]
+	Options AnnealingOptions
+	// Penalty total weight of the constraints violated by the best assignment found
+	Penalty float64
+	// Steps number of steps taken by the last call to Solve
+	Steps int
+}
+
+// AnnealingOptions configuration for AnnealingSolver
+type AnnealingOptions struct {
+	// MaxSteps stop after this many steps. Defaults to DefaultMaxSteps.
+	MaxSteps int
+	// Cooling the cooling schedule
+	Cooling CoolingSchedule
+	// InitialTemperature starting temperature. Defaults to DefaultInitialTemperature.
+	InitialTemperature float64
+	// FinalTemperature temperature at the last step of LinearCooling. Defaults to DefaultFinalTemperature.
+	FinalTemperature float64
+	// CoolingRate factor for GeometricCooling. Defaults to DefaultCoolingRate.
+	CoolingRate float64
+	// Seed seed for the initial assignment and every random choice
+	Seed int64
+}
+
+// NewAnnealingSolver create a simulated annealing solver
+func NewAnnealingSolver[T comparable](vars Variables[T], constraints Constraints[T]) AnnealingSolver[T] {
+	return AnnealingSolver[T]{State: CSPState[T]{Vars: vars, Constraints: constraints, Propagations: []Propagation[T]{}}}
+}
+
+// temperature the temperature at the given step
+func (options *AnnealingOptions) temperature(step int, maxSteps int) float64 {
+	initial := options.InitialTemperature
+	if initial <= 0 {
+		initial = DefaultInitialTemperature
+	}
+	switch options.Cooling {
+	case LinearCooling:
+		final := options.FinalTemperature
+		if final <= 0 {
+			final = DefaultFinalTemperature
+		}
+		return initial + (final-initial)*float64(step)/float64(maxSteps)
+	case LogarithmicCooling:
+		return initial / (1 + math.Log(1+float64(step)))
+	default:
+		rate := options.CoolingRate
+		if rate <= 0 || rate >= 1 {
+			rate = DefaultCoolingRate
+		}
+		return initial * math.Pow(rate, float64(step))
+	}
+}
+
+// Solve run simulated annealing until every constraint is satisfied,
+// MaxSteps is reached or ctx is done. Variables that are already assigned
+// are left as they are. The best assignment found is left in State and its
+// Penalty recorded, whether or not it satisfies every constraint. Running
+// out of time is the usual way to stop, so it is not an error. Returns true
+// if every constraint is satisfied.
+func (solver *AnnealingSolver[T]) Solve(ctx context.Context) (bool, error) {
+	state := &solver.State
+	state.compile()
+	random := rand.New(rand.NewSource(solver.Options.Seed))
+	solver.Steps = 0
+
+	free, ok := assignRandomly(state, random)
+	if !ok {
+		return false, nil
+	}
+	tracker := newViolationTracker(state)
+	best := newIncumbent(tracker)
+	maxSteps := solver.Options.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxSteps
+	}
+	done := ctx.Done()
+	for step := 1; step <= maxSteps && tracker.count() > 0 && !finished(done); step++ {
+		solver.Steps = step
+		i := tracker.conflicted(random, free, false)
+		if i < 0 {
+			break
+		}
+		variable := &state.Vars[i]
+		value := variable.Domain[random.Intn(len(variable.Domain))]
+		if value == variable.Value {
+			continue
+		}
+		delta := tracker.penaltyWith(i, value) - tracker.penaltyOf(i)
+		temperature := solver.Options.temperature(step, maxSteps)
+		if delta > 0 && (temperature <= 0 || random.Float64() >= math.Exp(-delta/temperature)) {
+			continue
+		}
+		tracker.reassign(i, value)
+		best.update(tracker)
+		if state.index.debug {
+			state.trace("reassign", "variable", variable.Name, "value", value, "penalty", tracker.penalty, "temperature", temperature)
+		}
+	}
+	best.restore(state)
+	solver.Penalty = best.penalty
+	return best.violated == 0, nil
+}
+
+// TabuSearchSolver tabu search for over-constrained problems. It minimises
+// the total Weight of the violated constraints. At every step it makes the
+// best change to a variable in a violated constraint, even one that makes
+// things worse, but may not undo a recent change unless that would beat the
+// best assignment found so far.
+type TabuSearchSolver[T comparable] struct {
+	State   CSPState[T]
+	Options TabuSearchOptions
+	// Penalty total weight of the constraints violated by the best assignment found
+	Penalty float64
+	// Steps number of steps taken by the last call to Solve
+	Steps int
+}
+
+// TabuSearchOptions configuration for TabuSearchSolver
+type TabuSearchOptions struct {
+	// MaxSteps stop after this many steps. Defaults to DefaultMaxSteps.
+	MaxSteps int
+	// TabuTenure number of steps for which a variable may not go back to a
+	// value it just left. Defaults to DefaultTabuTenure.
+	TabuTenure int
+	// Seed seed for the initial assignment and for breaking ties
+	Seed int64
+}
+
+// NewTabuSearchSolver create a tabu search solver
+func NewTabuSearchSolver[T comparable](vars Variables[T], constraints Constraints[T]) TabuSearchSolver[T] {
+	return TabuSearchSolver[T]{State: CSPState[T]{Vars: vars, Constraints: constraints, Propagations: []Propagation[T]{}}}
+}
+
+// Solve run tabu search until every constraint is satisfied, MaxSteps is
+// reached or ctx is done. As with AnnealingSolver, the best assignment found
+// is left in State and running out of time is not an error. Returns true if
+// every constraint is satisfied.
+func (solver *TabuSearchSolver[T]) Solve(ctx context.Context) (bool, error) {
+	state := &solver.State
+	state.compile()
+	random := rand.New(rand.NewSource(solver.Options.Seed))
+	solver.Steps = 0
+
+	free, ok := assignRandomly(state, random)
+	if !ok {
+		return false, nil
+	}
+	tracker := newViolationTracker(state)
+	best := newIncumbent(tracker)
+	maxSteps, tenure := solver.Options.MaxSteps, solver.Options.TabuTenure
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxSteps
+	}
+	if tenure <= 0 {
+		tenure = DefaultTabuTenure
+	}
+	tabu := make([]map[T]int, len(state.Vars))
+	for i := range tabu {
+		tabu[i] = make(map[T]int)
+	}
+	// seen step at which each variable was last considered as a candidate
+	seen := make([]int, len(state.Vars))
+	done := ctx.Done()
+	for step := 1; step <= maxSteps && tracker.count() > 0 && !finished(done); step++ {
+		solver.Steps = step
+		move, moveDelta, ties := -1, 0.0, 0
+		var moveValue T
+		for _, c := range tracker.violated {
+			for _, i := range state.index.constraints[c] {
+				if !free[i] || seen[i] == step {
+					continue
+				}
+				seen[i] = step
+				current := tracker.penaltyOf(i)
+				for _, option := range state.Vars[i].Domain {
+					if option == state.Vars[i].Value {
+						continue
+					}
+					delta := tracker.penaltyWith(i, option) - current
+					if until, ok := tabu[i][option]; ok && until >= step && tracker.penalty+delta >= best.penalty {
+						continue
+					}
+					if move < 0 || delta < moveDelta {
+						move, moveValue, moveDelta, ties = i, option, delta, 1
+					} else if delta == moveDelta {
+						ties++
+						if random.Intn(ties) == 0 {
+							move, moveValue = i, option
+						}
+					}
+				}
+			}
+		}
+		if move < 0 {
+			// every move is tabu
+			continue
+		}
+		tabu[move][state.Vars[move].Value] = step + tenure
+		tracker.reassign(move, moveValue)
+		best.update(tracker)
+		if state.index.debug {
+			state.trace("reassign", "variable", state.Vars[move].Name, "value", moveValue, "penalty", tracker.penalty)
+		}
+	}
+	best.restore(state)
+	solver.Penalty = best.penalty
+	return best.violated == 0, nil
+}
+
+// incumbent the best assignment found so far by a local search
+type incumbent[T comparable] struct {
+	values   []T
+	penalty  float64
+	violated int
+}
+
+func newIncumbent[T comparable](tracker *violationTracker[T]) *incumbent[T] {
+	best := &incumbent[T]{values: make([]T, len(tracker.state.Vars))}
+	best.save(tracker)
+	return best
+}
+
+// save record the current assignment
+func (best *incumbent[T]) save(tracker *violationTracker[T]) {
+	for i := range tracker.state.Vars {
+		best.values[i] = tracker.state.Vars[i].Value
+	}
+	best.penalty, best.violated = tracker.penalty, tracker.count()
+}
+
+// update record the current assignment if it is better than the incumbent
+func (best *incumbent[T]) update(tracker *violationTracker[T]) {
+	if tracker.penalty < best.penalty || (tracker.count() == 0 && best.violated > 0) {
+		best.save(tracker)
+	}
+}
+
+// restore put the incumbent back into the state
+func (best *incumbent[T]) restore(state *CSPState[T]) {
+	for i := range state.Vars {
+		state.Vars[i].Value = best.values[i]
+	}
+}
+
+// finished check whether a done channel has been closed
+func finished(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulatedAnnealing(t *testing.T) {
+	// four meetings that must all be in different rooms, but only three
+	// rooms. Keeping A and B apart matters far more than the rest.
+	vars := Variables[int]{
+		NewVariable("A", IntRange(0, 3)),
+		NewVariable("B", IntRange(0, 3)),
+		NewVariable("C", IntRange(0, 3)),
+		NewVariable("D", IntRange(0, 3)),
+	}
+	constraints := AllUnique[int]("A", "B", "C", "D")
+	for i := range constraints {
+		if constraints[i].Vars.Contains("A") && constraints[i].Vars.Contains("B") {
+			constraints[i].Weight = 10
+		}
+	}
+	for _, cooling := range []CoolingSchedule{GeometricCooling, LinearCooling, LogarithmicCooling} {
+		solver := NewAnnealingSolver(vars.Copy(), constraints)
+		solver.Options = AnnealingOptions{Cooling: cooling, MaxSteps: 2000, Seed: 3}
+		success, err := solver.Solve(context.TODO())
+		assert.Nil(t, err)
+		assert.False(t, success)
+		assert.Equal(t, 1.0, solver.Penalty)
+		assert.True(t, solver.State.Vars.Complete())
+		assert.NotEqual(t, solver.State.Vars.Find("A").Value, solver.State.Vars.Find("B").Value)
+		assert.Equal(t, 2000, solver.Steps)
+	}
+
+	// a deadline stops the search with the best assignment so far
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	solver := NewAnnealingSolver(vars.Copy(), constraints)
+	success, err := solver.Solve(ctx)
+	assert.Nil(t, err)
+	assert.False(t, success)
+	assert.Equal(t, 0, solver.Steps)
+	assert.True(t, solver.State.Vars.Complete())
+	assert.GreaterOrEqual(t, solver.Penalty, 1.0)
+
+	vars, constraints = queensProblem(16)
+	solver = NewAnnealingSolver(vars, constraints)
+	solver.Options.Seed = 1
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.Equal(t, 0.0, solver.Penalty)
+	assert.True(t, constraints.AllSatisfied(&solver.State.Vars))
+}
+
+func TestTabuSearch(t *testing.T) {
+	// the meetings from TestSimulatedAnnealing
+	vars := Variables[int]{
+		NewVariable("A", IntRange(0, 3)),
+		NewVariable("B", IntRange(0, 3)),
+		NewVariable("C", IntRange(0, 3)),
+		NewVariable("D", IntRange(0, 3)),
+	}
+	constraints := AllUnique[int]("A", "B", "C", "D")
+	for i := range constraints {
+		if constraints[i].Vars.Contains("A") && constraints[i].Vars.Contains("B") {
+			constraints[i].Weight = 10
+		}
+	}
+	solver := NewTabuSearchSolver(vars.Copy(), constraints)
+	solver.Options = TabuSearchOptions{MaxSteps: 200, TabuTenure: 3, Seed: 2}
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, success)
+	assert.Equal(t, 1.0, solver.Penalty)
+	assert.NotEqual(t, solver.State.Vars.Find("A").Value, solver.State.Vars.Find("B").Value)
+
+	// a deadline stops the search before the first step
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	solver = NewTabuSearchSolver(vars.Copy(), constraints)
+	success, err = solver.Solve(ctx)
+	assert.Nil(t, err)
+	assert.False(t, success)
+	assert.Equal(t, 0, solver.Steps)
+
+	vars, constraints = queensProblem(16)
+	solver = NewTabuSearchSolver(vars, constraints)
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.True(t, constraints.AllSatisfied(&solver.State.Vars))
+}
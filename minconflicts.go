@@ -0,0 +1,317 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"math/rand"
+)
+
+// DefaultMaxSteps number of steps taken when MinConflictsOptions.MaxSteps is not set
+const DefaultMaxSteps = 100000
+
+// MinConflictsSolver local search solver for large problems that are too big
+// for backtracking. It starts from a random complete assignment and keeps
+// reassigning a variable in a violated constraint to the value that
+// violates the fewest constraints, until none are violated. Soft
+// constraints are ignored, so it stops as soon as every hard constraint is
+// satisfied. It is not complete: failing to find a solution does not mean
+// there is none.
+type MinConflictsSolver[T comparable] struct {
+	State   CSPState[T]
+	Options MinConflictsOptions
+	// Steps number of reassignments made by the last call to Solve
+	Steps int
+}
+
+// MinConflictsOptions configuration for MinConflictsSolver
+type MinConflictsOptions struct {
+	// MaxSteps give up after this many reassignments. Defaults to DefaultMaxSteps.
+	MaxSteps int
+	// TabuTenure number of steps for which a variable may not go back to a
+	// value it just left, unless doing so beats the best assignment so far.
+	// 0 disables the tabu list.
+	TabuTenure int
+	// RandomWalk probability of giving the chosen variable a random value
+	// instead of the one with the fewest conflicts, to escape local minima
+	RandomWalk float64
+	// Seed seed for the initial assignment and every random choice
+	Seed int64
+}
+
+// NewMinConflictsSolver create a min-conflicts solver
+func NewMinConflictsSolver[T comparable](vars Variables[T], constraints Constraints[T]) MinConflictsSolver[T] {
+	return MinConflictsSolver[T]{State: CSPState[T]{Vars: vars, Constraints: constraints, Propagations: []Propagation[T]{}}}
+}
+
+// maxSteps MaxSteps or its default
+func (options *MinConflictsOptions) maxSteps() int {
+	if options.MaxSteps > 0 {
+		return options.MaxSteps
+	}
+	return DefaultMaxSteps
+}
+
+// Solve search for a solution. Variables that are already assigned are
+// left as they are. If no solution is found within MaxSteps, the other
+// variables are unset again and false is returned.
+func (solver *MinConflictsSolver[T]) Solve(ctx context.Context) (bool, error) {
+	state := &solver.State
+	state.compile()
+	random := rand.New(rand.NewSource(solver.Options.Seed))
+	solver.Steps = 0
+
+	free, ok := assignRandomly(state, random)
+	if !ok {
+		return false, nil
+	}
+	tracker := newViolationTracker(state)
+
+	tabu := make([]map[T]int, len(state.Vars))
+	for i := range tabu {
+		tabu[i] = make(map[T]int)
+	}
+	best := tracker.hard
+	done := ctx.Done()
+	for step := 1; step <= solver.Options.maxSteps(); step++ {
+		if tracker.hard == 0 {
+			return true, nil
+		}
+		if finished(done) {
+			unsetFree(state, free)
+			return false, ErrExecutionCanceled
+		}
+
+		i := tracker.conflicted(random, free, true)
+		if i < 0 {
+			// only pre-assigned variables are in conflict: no way out
+			break
+		}
+		variable := &state.Vars[i]
+		previous := variable.Value
+		value := previous
+		if random.Float64() < solver.Options.RandomWalk {
+			value = variable.Domain[random.Intn(len(variable.Domain))]
+		} else {
+			fewest, ties := -1, 0
+			for _, option := range variable.Domain {
+				conflicts := tracker.conflictsWith(i, option)
+				if until, ok := tabu[i][option]; ok && until >= step && tracker.hard-tracker.violatedBy(i)+conflicts >= best {
+					continue
+				}
+				if fewest < 0 || conflicts < fewest {
+					fewest, ties, value = conflicts, 1, option
+				} else if conflicts == fewest {
+					// pick uniformly among equally good values
+					ties++
+					if random.Intn(ties) == 0 {
+						value = option
+					}
+				}
+			}
+		}
+		solver.Steps = step
+		if value == previous {
+			continue
+		}
+		if solver.Options.TabuTenure > 0 {
+			tabu[i][previous] = step + solver.Options.TabuTenure
+		}
+		tracker.reassign(i, value)
+		if tracker.hard < best {
+			best = tracker.hard
+		}
+		if state.index.debug {
+			state.trace("reassign", "variable", variable.Name, "value", value, "violated", tracker.hard)
+		}
+	}
+	if tracker.hard == 0 {
+		return true, nil
+	}
+	unsetFree(state, free)
+	return false, nil
+}
+
+// assignRandomly give every unassigned variable a random value from its
+// domain. Returns which variables were unassigned, or false if one of them
+// has an empty domain.
+func assignRandomly[T comparable](state *CSPState[T], random *rand.Rand) ([]bool, bool) {
+	free := make([]bool, len(state.Vars))
+	for i := range state.Vars {
+		variable := &state.Vars[i]
+		if !variable.Empty {
+			continue
+		}
+		if len(variable.Domain) == 0 {
+			unsetFree(state, free)
+			return nil, false
+		}
+		free[i] = true
+		variable.SetValue(variable.Domain[random.Intn(len(variable.Domain))])
+	}
+	return free, true
+}
+
+// unsetFree unset the variables a local search assigned
+func unsetFree[T comparable](state *CSPState[T], free []bool) {
+	for i := range free {
+		if free[i] {
+			state.Vars[i].Unset()
+		}
+	}
+}
+
+// violationTracker keeps track of which constraints a complete assignment
+// violates as variables are reassigned one at a time
+type violationTracker[T comparable] struct {
+	state *CSPState[T]
+	// violated positions in Constraints of the violated constraints
+	violated []int
+	// position of each violated constraint in violated, -1 if satisfied
+	position []int
+	// penalty total weight of the violated constraints
+	penalty float64
+	// hard number of violated constraints that are not Soft
+	hard int
+}
+
+func newViolationTracker[T comparable](state *CSPState[T]) *violationTracker[T] {
+	tracker := &violationTracker[T]{state: state, violated: make([]int, 0), position: make([]int, len(state.Constraints))}
+	for c := range state.Constraints {
+		tracker.position[c] = -1
+		tracker.update(c)
+	}
+	return tracker
+}
+
+// count number of violated constraints
+func (tracker *violationTracker[T]) count() int {
+	return len(tracker.violated)
+}
+
+// update re-evaluate the constraint at position c
+func (tracker *violationTracker[T]) update(c int) {
+	satisfied := tracker.state.satisfied(c)
+	position := tracker.position[c]
+	if !satisfied && position < 0 {
+		tracker.position[c] = len(tracker.violated)
+		tracker.violated = append(tracker.violated, c)
+		tracker.penalty += tracker.state.Constraints[c].weight()
+		if !tracker.state.Constraints[c].Soft {
+			tracker.hard++
+		}
+	} else if satisfied && position >= 0 {
+		tracker.penalty -= tracker.state.Constraints[c].weight()
+		if !tracker.state.Constraints[c].Soft {
+			tracker.hard--
+		}
+		// swap the last violated constraint into its place
+		last := tracker.violated[len(tracker.violated)-1]
+		tracker.violated[position] = last
+		tracker.position[last] = position
+		tracker.violated = tracker.violated[:len(tracker.violated)-1]
+		tracker.position[c] = -1
+		if len(tracker.violated) == 0 {
+			// don't let rounding errors pile up
+			tracker.penalty = 0
+		}
+	}
+}
+
+// reassign give the variable at position i a new value
+func (tracker *violationTracker[T]) reassign(i int, value T) {
+	tracker.state.Vars[i].SetValue(value)
+	for _, c := range tracker.state.index.incident[i] {
+		tracker.update(c)
+	}
+	for _, c := range tracker.state.index.global {
+		tracker.update(c)
+	}
+}
+
+// violatedBy number of violated hard constraints on the variable at position i
+func (tracker *violationTracker[T]) violatedBy(i int) int {
+	count := 0
+	for _, c := range tracker.state.index.incident[i] {
+		if tracker.position[c] >= 0 && !tracker.state.Constraints[c].Soft {
+			count++
+		}
+	}
+	return count
+}
+
+// penaltyOf total weight of the violated constraints on the variable at position i
+func (tracker *violationTracker[T]) penaltyOf(i int) float64 {
+	penalty := 0.0
+	for _, c := range tracker.state.index.incident[i] {
+		if tracker.position[c] >= 0 {
+			penalty += tracker.state.Constraints[c].weight()
+		}
+	}
+	return penalty
+}
+
+// penaltyWith total weight of the constraints on the variable at position i
+// that would be violated if it took the given value
+func (tracker *violationTracker[T]) penaltyWith(i int, value T) float64 {
+	variable := &tracker.state.Vars[i]
+	previous := variable.Value
+	variable.Value = value
+	penalty := 0.0
+	for _, c := range tracker.state.index.incident[i] {
+		if !tracker.state.satisfied(c) {
+			penalty += tracker.state.Constraints[c].weight()
+		}
+	}
+	variable.Value = previous
+	return penalty
+}
+
+// conflictsWith number of hard constraints on the variable at position i
+// that would be violated if it took the given value
+func (tracker *violationTracker[T]) conflictsWith(i int, value T) int {
+	variable := &tracker.state.Vars[i]
+	previous := variable.Value
+	variable.Value = value
+	count := 0
+	for _, c := range tracker.state.index.incident[i] {
+		if !tracker.state.Constraints[c].Soft && !tracker.state.satisfied(c) {
+			count++
+		}
+	}
+	variable.Value = previous
+	return count
+}
+
+// conflicted pick a random free variable from a random violated constraint,
+// only considering hard constraints if hard is true. Returns -1 if no such
+// constraint has a free variable.
+func (tracker *violationTracker[T]) conflicted(random *rand.Rand, free []bool, hard bool) int {
+	start := random.Intn(len(tracker.violated))
+	for k := range tracker.violated {
+		c := tracker.violated[(start+k)%len(tracker.violated)]
+		indices := tracker.state.index.constraints[c]
+		if len(indices) == 0 || (hard && tracker.state.Constraints[c].Soft) {
+			continue
+		}
+		offset := random.Intn(len(indices))
+		for j := range indices {
+			if i := indices[(offset+j)%len(indices)]; free[i] {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinConflictsQueens(t *testing.T) {
+	vars, constraints := queensProblem(64)
+	solver := NewMinConflictsSolver(vars.Copy(), constraints)
+	solver.Options = MinConflictsOptions{TabuTenure: 5, RandomWalk: 0.02, Seed: 1}
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.True(t, solver.State.Vars.Complete())
+	assert.True(t, constraints.AllSatisfied(&solver.State.Vars))
+	assert.Greater(t, solver.Steps, 0)
+
+	// the same seed takes the same steps
+	again := NewMinConflictsSolver(vars.Copy(), constraints)
+	again.Options = solver.Options
+	success, err = again.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.Equal(t, solver.Steps, again.Steps)
+	assert.Equal(t, solver.State.Vars, again.State.Vars)
+}
+
+func TestMinConflictsPreAssigned(t *testing.T) {
+	vars, constraints := queensProblem(8)
+	vars.SetValue("Q0", 3)
+	solver := NewMinConflictsSolver(vars, constraints)
+	solver.Options.Seed = 7
+	solver.Options.RandomWalk = 0.1
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.Equal(t, 3, solver.State.Vars.Find("Q0").Value)
+	assert.True(t, constraints.AllSatisfied(&solver.State.Vars))
+
+	// unsatisfiable: gives up after MaxSteps and leaves the free variables unset
+	solver = NewMinConflictsSolver(Variables[int]{
+		NewVariable("A", IntRange(0, 2)),
+		NewVariable("B", IntRange(0, 2)),
+		NewVariable("C", IntRange(0, 2)),
+	}, AllUnique[int]("A", "B", "C"))
+	solver.Options.MaxSteps = 100
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, success)
+	assert.Equal(t, 100, solver.Steps)
+	assert.Equal(t, 3, solver.State.Vars.Unassigned())
+}
+
+func TestMinConflictsSoft(t *testing.T) {
+	// the soft constraint can never hold alongside the hard ones, so only the
+	// hard ones have to be satisfied
+	constraints := append(AllUnique[int]("A", "B"), SoftConstraint(Equals[int]("A", "B"), 1))
+	solver := NewMinConflictsSolver(Variables[int]{
+		NewVariable("A", IntRange(0, 2)),
+		NewVariable("B", IntRange(0, 2)),
+	}, constraints)
+	solver.Options.MaxSteps = 100
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.True(t, solver.State.Vars.Complete())
+	assert.NotEqual(t, solver.State.Vars.Find("A").Value, solver.State.Vars.Find("B").Value)
+}
+
+func TestMinConflictsTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	vars, constraints := queensProblem(8)
+	solver := NewMinConflictsSolver(vars, constraints)
+	success, err := solver.Solve(ctx)
+	assert.False(t, success)
+	assert.ErrorIs(t, err, ErrExecutionCanceled)
+	assert.Equal(t, 8, solver.State.Vars.Unassigned())
+}
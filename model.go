@@ -0,0 +1,152 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"fmt"
+	"time"
+)
+
+// Model CSP model whose variables may each hold a different type of value.
+// Every variable is stored as an int index into its own typed domain, which
+// lets the whole model be solved by a single BackTrackingCSPSolver[int].
+// Variables are added with NewVar (or NewIntVar, NewTimeVar, NewEnumVar)
+// and read back through the typed handle that is returned.
+type Model struct {
+	Vars        Variables[int]
+	Constraints Constraints[int]
+}
+
+// NewModel create an empty Model
+func NewModel() *Model {
+	return &Model{Vars: make(Variables[int], 0), Constraints: make(Constraints[int], 0)}
+}
+
+// AddConstraints add constraints built from typed handles to the model
+func (model *Model) AddConstraints(constraints ...Constraint[int]) {
+	model.Constraints = append(model.Constraints, constraints...)
+}
+
+// Solver create a solver for the model
+func (model *Model) Solver() BackTrackingCSPSolver[int] {
+	return NewBackTrackingCSPSolver(model.Vars, model.Constraints)
+}
+
+// Var typed handle to a variable in a Model
+type Var[T comparable] struct {
+	Name   VariableName
+	Domain Domain[T]
+	// index position of the variable in the model's Vars
+	index int
+}
+
+// IntVar handle to an int variable in a Model
+type IntVar = Var[int]
+
+// TimeVar handle to a time.Time variable in a Model
+type TimeVar = Var[time.Time]
+
+// NewVar add a variable with the given typed domain to the model
+func NewVar[T comparable](model *Model, name VariableName, domain Domain[T]) Var[T] {
+	if model.Vars.Contains(name) {
+		panic(fmt.Sprintf("Variable %v already exists in model", name))
+	}
+	model.Vars = append(model.Vars, NewVariable(name, IntRange(0, len(domain))))
+	return Var[T]{Name: name, Domain: domain, index: len(model.Vars) - 1}
+}
+
+// NewIntVar add an int variable to the model
+func NewIntVar(model *Model, name VariableName, domain Domain[int]) IntVar {
+	return NewVar(model, name, domain)
+}
+
+// NewTimeVar add a time.Time variable to the model
+func NewTimeVar(model *Model, name VariableName, domain Domain[time.Time]) TimeVar {
+	return NewVar(model, name, domain)
+}
+
+// NewEnumVar add a variable to the model that takes one of the given values
+func NewEnumVar[T comparable](model *Model, name VariableName, values ...T) Var[T] {
+	return NewVar(model, name, Domain[T](values))
+}
+
+// Value typed value of this variable in the given Variables. Returns
+// false if the variable has not been assigned to.
+func (handle Var[T]) Value(variables *Variables[int]) (T, bool) {
+	variable := handle.find(variables)
+	if variable.Empty {
+		var zero T
+		return zero, false
+	}
+	return handle.Domain[variable.Value], true
+}
+
+// find look up the variable at the position it was created with,
+// falling back to a search by name
+func (handle Var[T]) find(variables *Variables[int]) *Variable[int] {
+	if handle.index < len(*variables) && variables.At(handle.index).Name == handle.Name {
+		return variables.At(handle.index)
+	}
+	return variables.Find(handle.Name)
+}
+
+// SetValue assign a typed value to this variable in the given Variables
+func (handle Var[T]) SetValue(variables *Variables[int], value T) {
+	for index, item := range handle.Domain {
+		if item == value {
+			handle.find(variables).SetValue(index)
+			return
+		}
+	}
+	panic(fmt.Sprintf("Variable %v with domain %v does not support value %v", handle.Name, handle.Domain, value))
+}
+
+// Predicate Constraint generator that checks a typed condition on one variable
+func Predicate[A comparable](a Var[A], fx func(A) bool) Constraint[int] {
+	return Constraint[int]{Vars: VariableNames{a.Name}, ConstraintFunction: func(variables *Variables[int]) bool {
+		valueA, ok := a.Value(variables)
+		if !ok {
+			return true
+		}
+		return fx(valueA)
+	}}
+}
+
+// Relation Constraint generator that checks a typed relation between two
+// variables, which may be of different types
+func Relation[A comparable, B comparable](a Var[A], b Var[B], fx func(A, B) bool) Constraint[int] {
+	return Constraint[int]{Vars: VariableNames{a.Name, b.Name}, ConstraintFunction: func(variables *Variables[int]) bool {
+		valueA, okA := a.Value(variables)
+		valueB, okB := b.Value(variables)
+		if !okA || !okB {
+			return true
+		}
+		return fx(valueA, valueB)
+	}}
+}
+
+// Relation3 Constraint generator that checks a typed relation between three
+// variables, which may be of different types
+func Relation3[A comparable, B comparable, C comparable](a Var[A], b Var[B], c Var[C], fx func(A, B, C) bool) Constraint[int] {
+	return Constraint[int]{Vars: VariableNames{a.Name, b.Name, c.Name}, ConstraintFunction: func(variables *Variables[int]) bool {
+		valueA, okA := a.Value(variables)
+		valueB, okB := b.Value(variables)
+		valueC, okC := c.Value(variables)
+		if !okA || !okB || !okC {
+			return true
+		}
+		return fx(valueA, valueB, valueC)
+	}}
+}
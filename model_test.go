@@ -0,0 +1,77 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeterogeneousModel(t *testing.T) {
+	day := time.Date(2022, time.March, 1, 9, 0, 0, 0, time.UTC)
+	hours := TimeRangeStep(day, day.Add(4*time.Hour), time.Hour) // 9:00 to 12:00
+
+	model := NewModel()
+	// two meetings, each with a start time, a room and a length in hours
+	standupStart := NewTimeVar(model, "StandupStart", hours)
+	standupRoom := NewEnumVar(model, "StandupRoom", "Small", "Large")
+	reviewStart := NewTimeVar(model, "ReviewStart", hours)
+	reviewRoom := NewEnumVar(model, "ReviewRoom", "Small", "Large")
+	reviewLength := NewIntVar(model, "ReviewLength", IntRange(1, 4))
+
+	// the review needs the large room and at least two hours
+	model.AddConstraints(
+		Predicate(reviewRoom, func(room string) bool { return room == "Large" }),
+		Predicate(reviewLength, func(length int) bool { return length >= 2 }),
+		// the review has to end by noon
+		Relation(reviewStart, reviewLength, func(start time.Time, length int) bool {
+			return !start.Add(time.Duration(length) * time.Hour).After(day.Add(3 * time.Hour))
+		}),
+		// the standup happens after the review ends
+		Relation3(reviewStart, reviewLength, standupStart, func(start time.Time, length int, standup time.Time) bool {
+			return !standup.Before(start.Add(time.Duration(length) * time.Hour))
+		}),
+		// the standup can't use the same room as the review
+		Relation(standupRoom, reviewRoom, func(room1 string, room2 string) bool { return room1 != room2 }),
+	)
+
+	solver := model.Solver()
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+
+	start, ok := reviewStart.Value(&solver.State.Vars)
+	assert.True(t, ok)
+	assert.Equal(t, day, start)
+	length, _ := reviewLength.Value(&solver.State.Vars)
+	assert.Equal(t, 2, length)
+	room, _ := reviewRoom.Value(&solver.State.Vars)
+	assert.Equal(t, "Large", room)
+	standup, _ := standupStart.Value(&solver.State.Vars)
+	assert.Equal(t, day.Add(2*time.Hour), standup)
+	room, _ = standupRoom.Value(&solver.State.Vars)
+	assert.Equal(t, "Small", room)
+
+	// typed values can also be assigned before solving
+	model = NewModel()
+	color := NewEnumVar(model, "Color", "red", "green")
+	color.SetValue(&model.Vars, "green")
+	value, ok := color.Value(&model.Vars)
+	assert.True(t, ok)
+	assert.Equal(t, "green", value)
+}
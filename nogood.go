@@ -0,0 +1,225 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	// ErrUnknownVariable a nogood or assumption names a variable that isn't
+	// in the problem
+	ErrUnknownVariable error = errors.New("refers to an unknown variable")
+)
+
+// DefaultNogoodLimit number of nogoods kept when SolverOptions.NogoodLimit is not set
+const DefaultNogoodLimit = 1000
+
+// Nogood set of variable assignments proven not to be part of any solution
+type Nogood[T comparable] []VariableAssignment[T]
+
+// EvictionPolicy decides which nogood to forget once the database is full
+type EvictionPolicy int
+
+const (
+	// EvictLeastRecentlyUsed forget the nogood that has gone longest without pruning anything
+	EvictLeastRecentlyUsed EvictionPolicy = iota
+	// EvictOldest forget the nogood that was learned first
+	EvictOldest
+	// EvictLongest forget the nogood with the most assignments, which is the least likely to prune
+	EvictLongest
+)
+
+// learnedNogood a nogood along with the bookkeeping used for eviction
+type learnedNogood[T comparable] struct {
+	nogood  Nogood[T]
+	created int
+	used    int
+}
+
+// nogoodDatabase bounded store of nogoods, indexed by each of their assignments
+type nogoodDatabase[T comparable] struct {
+	nogoods []*learnedNogood[T]
+	index   map[VariableAssignment[T]][]*learnedNogood[T]
+	// tick logical clock for the eviction policies
+	tick int
+}
+
+func newNogoodDatabase[T comparable]() *nogoodDatabase[T] {
+	return &nogoodDatabase[T]{nogoods: make([]*learnedNogood[T], 0), index: make(map[VariableAssignment[T]][]*learnedNogood[T])}
+}
+
+// add store a nogood, evicting another if the database already holds limit
+// nogoods. Returns false if the nogood was empty or already known.
+func (database *nogoodDatabase[T]) add(nogood Nogood[T], limit int, policy EvictionPolicy) bool {
+	if len(nogood) == 0 || limit <= 0 {
+		return false
+	}
+	// keep assignments sorted by name so equal nogoods compare equal
+	nogood = append(Nogood[T]{}, nogood...)
+	sort.Slice(nogood, func(i, j int) bool { return nogood[i].VariableName < nogood[j].VariableName })
+	for _, existing := range database.index[nogood[0]] {
+		if existing.nogood.equals(nogood) {
+			return false
+		}
+	}
+	for len(database.nogoods) >= limit {
+		database.evict(policy)
+	}
+	database.tick++
+	learned := &learnedNogood[T]{nogood: nogood, created: database.tick, used: database.tick}
+	database.nogoods = append(database.nogoods, learned)
+	for _, assignment := range nogood {
+		database.index[assignment] = append(database.index[assignment], learned)
+	}
+	return true
+}
+
+// evict remove one nogood according to the eviction policy
+func (database *nogoodDatabase[T]) evict(policy EvictionPolicy) {
+	victim := 0
+	for i, learned := range database.nogoods {
+		current := database.nogoods[victim]
+		switch policy {
+		case EvictOldest:
+			if learned.created < current.created {
+				victim = i
+			}
+		case EvictLongest:
+			if len(learned.nogood) > len(current.nogood) {
+				victim = i
+			}
+		default:
+			if learned.used < current.used {
+				victim = i
+			}
+		}
+	}
+	removed := database.nogoods[victim]
+	database.nogoods = append(database.nogoods[:victim], database.nogoods[victim+1:]...)
+	for _, assignment := range removed.nogood {
+		watching := database.index[assignment]
+		for i := range watching {
+			if watching[i] == removed {
+				database.index[assignment] = append(watching[:i], watching[i+1:]...)
+				break
+			}
+		}
+		if len(database.index[assignment]) == 0 {
+			delete(database.index, assignment)
+		}
+	}
+}
+
+// forget remove every nogood for which drop returns true
+func (database *nogoodDatabase[T]) forget(drop func(learned *learnedNogood[T]) bool) {
+	kept := database.nogoods[:0]
+	database.index = make(map[VariableAssignment[T]][]*learnedNogood[T])
+	for _, learned := range database.nogoods {
+		if drop(learned) {
+			continue
+		}
+		kept = append(kept, learned)
+		for _, assignment := range learned.nogood {
+			database.index[assignment] = append(database.index[assignment], learned)
+		}
+	}
+	database.nogoods = kept
+}
+
+// violated find a nogood containing the given assignment whose other
+// assignments all hold in state. Returns nil if there is none.
+func (database *nogoodDatabase[T]) violated(assignment VariableAssignment[T], state *CSPState[T]) Nogood[T] {
+	for _, learned := range database.index[assignment] {
+		holds := true
+		for _, other := range learned.nogood {
+			if other.VariableName == assignment.VariableName {
+				continue
+			}
+			variable := state.find(other.VariableName)
+			if variable.Empty || variable.Value != other.Value {
+				holds = false
+				break
+			}
+		}
+		if holds {
+			database.tick++
+			learned.used = database.tick
+			return learned.nogood
+		}
+	}
+	return nil
+}
+
+// list copy of every nogood in the database
+func (database *nogoodDatabase[T]) list() []Nogood[T] {
+	nogoods := make([]Nogood[T], 0, len(database.nogoods))
+	for _, learned := range database.nogoods {
+		nogoods = append(nogoods, append(Nogood[T]{}, learned.nogood...))
+	}
+	return nogoods
+}
+
+// equals check if two sorted nogoods contain the same assignments
+func (nogood Nogood[T]) equals(other Nogood[T]) bool {
+	if len(nogood) != len(other) {
+		return false
+	}
+	for i := range nogood {
+		if nogood[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Nogoods export the nogoods learned so far, e.g. to import them into
+// another solver for the same model with AddNogoods.
+func (solver *BackTrackingCSPSolver[T]) Nogoods() []Nogood[T] {
+	if solver.nogoods == nil {
+		return []Nogood[T]{}
+	}
+	return solver.nogoods.list()
+}
+
+// AddNogoods import nogoods, such as ones exported from an earlier run of the
+// same model. They are used to prune the search whether or not learning is
+// enabled. Nogoods on variables that aren't in State are left out, and
+// ErrUnknownVariable is returned once the rest have been imported.
+func (solver *BackTrackingCSPSolver[T]) AddNogoods(nogoods ...Nogood[T]) error {
+	if solver.nogoods == nil {
+		solver.nogoods = newNogoodDatabase[T]()
+	}
+	var err error
+	for _, nogood := range nogoods {
+		if !solver.State.knows(nogood) {
+			err = ErrUnknownVariable
+			continue
+		}
+		solver.nogoods.add(nogood, solver.Options.nogoodLimit(), solver.Options.NogoodEviction)
+	}
+	return err
+}
+
+// knows whether every variable in the nogood is in Vars
+func (state *CSPState[T]) knows(nogood Nogood[T]) bool {
+	for _, assignment := range nogood {
+		if state.Vars.IndexOf(assignment.VariableName) < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNogoodLearning(t *testing.T) {
+	evaluations := 0
+	vars, constraints := pigeonholeProblem(&evaluations)
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, success)
+	withoutLearning := evaluations
+
+	evaluations = 0
+	vars, constraints = pigeonholeProblem(&evaluations)
+	solver = NewBackTrackingCSPSolver(vars, constraints)
+	solver.Options.LearnNogoods = true
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, success)
+	// the failure is learned in the first branch and pruned straight away in the others
+	assert.Less(t, evaluations*10, withoutLearning)
+	assert.Contains(t, solver.Nogoods(), Nogood[int]{{VariableName: "P", Value: 0}})
+	assert.Contains(t, solver.Nogoods(), Nogood[int]{{VariableName: "P", Value: 1}})
+
+	// learned nogoods can be imported into another run of the same model
+	evaluations = 0
+	vars, constraints = pigeonholeProblem(&evaluations)
+	imported := NewBackTrackingCSPSolver(vars, constraints)
+	assert.Nil(t, imported.AddNogoods(solver.Nogoods()...))
+	success, err = imported.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, success)
+	// only the initial check of the constraints is needed
+	assert.Equal(t, 3, evaluations)
+
+	// nogoods from a different model are left out
+	imported = NewBackTrackingCSPSolver(vars, constraints)
+	err = imported.AddNogoods(Nogood[int]{{VariableName: "P", Value: 0}}, Nogood[int]{{VariableName: "P", Value: 1}, {VariableName: "S", Value: 1}})
+	assert.ErrorIs(t, err, ErrUnknownVariable)
+	assert.Equal(t, []Nogood[int]{{{VariableName: "P", Value: 0}}}, imported.Nogoods())
+}
+
+func TestNogoodEviction(t *testing.T) {
+	nogood := func(values ...int) Nogood[int] {
+		nogood := make(Nogood[int], 0)
+		for i, value := range values {
+			nogood = append(nogood, VariableAssignment[int]{VariableName: VariableName(fmt.Sprintf("V%d", i)), Value: value})
+		}
+		return nogood
+	}
+	vars := Variables[int]{
+		NewVariable("V0", IntRange(0, 3)),
+		NewVariable("V1", IntRange(0, 3)),
+		NewVariable("V2", IntRange(0, 3)),
+	}
+	state := CSPState[int]{Vars: vars}
+	state.compile()
+
+	database := newNogoodDatabase[int]()
+	assert.True(t, database.add(nogood(0, 0), 2, EvictLeastRecentlyUsed))
+	assert.True(t, database.add(nogood(1, 1, 1), 2, EvictLeastRecentlyUsed))
+	assert.False(t, database.add(nogood(0, 0), 2, EvictLeastRecentlyUsed))
+
+	// use the first nogood so that the second is the least recently used
+	state.Vars.SetValue("V1", 0)
+	assert.Equal(t, nogood(0, 0), database.violated(VariableAssignment[int]{VariableName: "V0", Value: 0}, &state))
+	assert.True(t, database.add(nogood(2, 2), 2, EvictLeastRecentlyUsed))
+	assert.Equal(t, []Nogood[int]{nogood(0, 0), nogood(2, 2)}, database.list())
+
+	assert.True(t, database.add(nogood(1, 2, 0), 2, EvictOldest))
+	assert.Equal(t, []Nogood[int]{nogood(2, 2), nogood(1, 2, 0)}, database.list())
+
+	assert.True(t, database.add(nogood(0, 1), 2, EvictLongest))
+	assert.Equal(t, []Nogood[int]{nogood(2, 2), nogood(0, 1)}, database.list())
+	assert.Nil(t, database.violated(VariableAssignment[int]{VariableName: "V0", Value: 1}, &state))
+
+	// the solver respects its limit
+	evaluations := 0
+	vars, constraints := pigeonholeProblem(&evaluations)
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	solver.Options.LearnNogoods = true
+	solver.Options.NogoodLimit = 1
+	_, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.Len(t, solver.Nogoods(), 1)
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"math"
+)
+
+// Objective a cost to be minimised
+type Objective[T comparable] struct {
+	Name string
+	// Cost cost of a complete assignment
+	Cost func(variables *Variables[T]) float64
+	// LowerBound optional lowest Cost that any completion of a partial
+	// assignment could have. Without it, branches can only be pruned once
+	// every variable is assigned.
+	LowerBound func(variables *Variables[T]) float64
+}
+
+// ObjectiveSolution a solution and its cost under each objective
+type ObjectiveSolution[T comparable] struct {
+	Vars  Variables[T]
+	Costs []float64
+}
+
+// ObjectiveResult outcome of SolvePareto or SolveLexicographic
+type ObjectiveResult[T comparable] struct {
+	// Solutions the Pareto front, or the single best solution in lexicographic mode
+	Solutions []ObjectiveSolution[T]
+	// Optimal whether the search finished, proving nothing better was missed
+	Optimal bool
+}
+
+// lowerBound lowest cost any completion of the current assignment could have
+func (objective *Objective[T]) lowerBound(variables *Variables[T]) float64 {
+	if variables.Complete() {
+		return objective.Cost(variables)
+	}
+	if objective.LowerBound == nil {
+		return math.Inf(-1)
+	}
+	return objective.LowerBound(variables)
+}
+
+// dominates whether costs a are at least as good as b under every objective
+func dominates(a []float64, b []float64) bool {
+	for k := range a {
+		if a[k] > b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// lexicographicallyLess whether costs a beat b on the first objective where they differ
+func lexicographicallyLess(a []float64, b []float64) bool {
+	for k := range a {
+		if a[k] != b[k] {
+			return a[k] < b[k]
+		}
+	}
+	return false
+}
+
+// SolvePareto find the Pareto front: every solution that no other solution
+// beats under one objective without doing worse under another. Only one
+// solution is kept for each combination of costs. Branches whose lower
+// bounds are already matched by a solution on the front are pruned. If ctx
+// finishes first, the front found so far is returned, not marked Optimal.
+func (solver *BackTrackingCSPSolver[T]) SolvePareto(ctx context.Context, objectives ...Objective[T]) (ObjectiveResult[T], error) {
+	front := make([]ObjectiveSolution[T], 0)
+	s := newSearch(ctx, solver)
+	s.bound = func() bool {
+		bounds := evaluateObjectives(objectives, &s.state.Vars, true)
+		for _, solution := range front {
+			if dominates(solution.Costs, bounds) {
+				return false
+			}
+		}
+		return true
+	}
+	s.onSolution = func() bool {
+		costs := evaluateObjectives(objectives, &s.state.Vars, false)
+		for _, solution := range front {
+			if dominates(solution.Costs, costs) {
+				return false
+			}
+		}
+		// drop whatever the new solution dominates
+		kept := front[:0]
+		for _, solution := range front {
+			if !dominates(costs, solution.Costs) {
+				kept = append(kept, solution)
+			}
+		}
+		front = append(kept, ObjectiveSolution[T]{Vars: s.state.Vars.Copy(), Costs: costs})
+		if s.state.index.debug {
+			s.state.trace("solution", "costs", costs, "front", len(front))
+		}
+		return false
+	}
+	s.run()
+	return objectiveResult(s, front)
+}
+
+// SolveLexicographic find the solution with the lowest cost under the first
+// objective, breaking ties by the second, and so on. The best solution is
+// left in State. If ctx finishes first, the best solution found so far is
+// kept, not marked Optimal.
+func (solver *BackTrackingCSPSolver[T]) SolveLexicographic(ctx context.Context, objectives ...Objective[T]) (ObjectiveResult[T], error) {
+	var best *ObjectiveSolution[T]
+	s := newSearch(ctx, solver)
+	s.bound = func() bool {
+		return best == nil || lexicographicallyLess(evaluateObjectives(objectives, &s.state.Vars, true), best.Costs)
+	}
+	s.onSolution = func() bool {
+		costs := evaluateObjectives(objectives, &s.state.Vars, false)
+		if best == nil || lexicographicallyLess(costs, best.Costs) {
+			best = &ObjectiveSolution[T]{Vars: s.state.Vars.Copy(), Costs: costs}
+			if s.state.index.debug {
+				s.state.trace("solution", "costs", costs)
+			}
+		}
+		return false
+	}
+	s.run()
+	if best == nil {
+		return objectiveResult[T](s, nil)
+	}
+	solver.State.Vars = best.Vars.Copy()
+	return objectiveResult(s, []ObjectiveSolution[T]{*best})
+}
+
+// evaluateObjectives cost of the assignment under each objective, or the
+// lower bound on the cost of any completion of it
+func evaluateObjectives[T comparable](objectives []Objective[T], variables *Variables[T], bound bool) []float64 {
+	costs := make([]float64, len(objectives))
+	for k := range objectives {
+		if bound {
+			costs[k] = objectives[k].lowerBound(variables)
+		} else {
+			costs[k] = objectives[k].Cost(variables)
+		}
+	}
+	return costs
+}
+
+// objectiveResult wrap up the solutions found by a finished search
+func objectiveResult[T comparable](s *search[T], solutions []ObjectiveSolution[T]) (ObjectiveResult[T], error) {
+	if len(solutions) == 0 && s.aborted {
+		return ObjectiveResult[T]{}, ErrExecutionCanceled
+	}
+	if solutions == nil {
+		solutions = []ObjectiveSolution[T]{}
+	}
+	return ObjectiveResult[T]{Solutions: solutions, Optimal: !s.aborted}, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectives(t *testing.T) {
+	// pick a shift for each of three employees. Earlier shifts are cheaper,
+	// but the employees would rather work later ones.
+	vars := Variables[int]{
+		NewVariable("Ann", IntRange(0, 3)),
+		NewVariable("Ben", IntRange(0, 3)),
+		NewVariable("Cat", IntRange(0, 3)),
+	}
+	sum := func(variables *Variables[int], value func(int) int) float64 {
+		total := 0
+		for _, variable := range *variables {
+			if !variable.Empty {
+				total += value(variable.Value)
+			}
+		}
+		return float64(total)
+	}
+	cost := Objective[int]{
+		Name: "cost",
+		Cost: func(variables *Variables[int]) float64 {
+			return sum(variables, func(shift int) int { return shift + 1 })
+		},
+		// unassigned employees cost at least 1
+		LowerBound: func(variables *Variables[int]) float64 {
+			return sum(variables, func(shift int) int { return shift + 1 }) + float64(variables.Unassigned())
+		},
+	}
+	unhappiness := Objective[int]{
+		Name: "unhappiness",
+		Cost: func(variables *Variables[int]) float64 {
+			return sum(variables, func(shift int) int { return 2 - shift })
+		},
+	}
+	objectives := []Objective[int]{cost, unhappiness}
+	// Ann and Ben can't both be on the same shift
+	constraints := Constraints[int]{NotEquals[int]("Ann", "Ben")}
+
+	solver := NewBackTrackingCSPSolver(vars.Copy(), constraints)
+	result, err := solver.SolvePareto(context.TODO(), objectives...)
+	assert.Nil(t, err)
+	assert.True(t, result.Optimal)
+
+	// every total from 4 to 8 is achievable, each trading one unit of cost for one of unhappiness
+	costs := make([][]float64, 0)
+	for _, solution := range result.Solutions {
+		costs = append(costs, solution.Costs)
+		assert.True(t, constraints.AllSatisfied(&solution.Vars))
+		assert.True(t, solution.Vars.Complete())
+	}
+	assert.ElementsMatch(t, [][]float64{{4, 5}, {5, 4}, {6, 3}, {7, 2}, {8, 1}}, costs)
+	for i, a := range result.Solutions {
+		for j, b := range result.Solutions {
+			// nothing on the front is at least as good as anything else on it
+			assert.True(t, i == j || !dominates(a.Costs, b.Costs))
+		}
+	}
+
+	// lexicographically, cost comes first
+	solver = NewBackTrackingCSPSolver(vars.Copy(), constraints)
+	result, err = solver.SolveLexicographic(context.TODO(), objectives...)
+	assert.Nil(t, err)
+	assert.True(t, result.Optimal)
+	assert.Len(t, result.Solutions, 1)
+	assert.Equal(t, []float64{4, 5}, result.Solutions[0].Costs)
+	assert.Equal(t, 0, solver.State.Vars.Find("Cat").Value)
+
+	// the other way round, happiness comes first
+	solver = NewBackTrackingCSPSolver(vars.Copy(), constraints)
+	result, err = solver.SolveLexicographic(context.TODO(), objectives[1], objectives[0])
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{1, 8}, result.Solutions[0].Costs)
+	assert.Equal(t, 2, solver.State.Vars.Find("Cat").Value)
+
+	// no solutions at all
+	solver = NewBackTrackingCSPSolver(vars.Copy(), append(constraints, Equals[int]("Ann", "Ben")))
+	result, err = solver.SolveLexicographic(context.TODO(), objectives...)
+	assert.Nil(t, err)
+	assert.True(t, result.Optimal)
+	assert.Empty(t, result.Solutions)
+}
@@ -0,0 +1,391 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"sync"
+)
+
+// tasksPerWorker how many subproblems to split the tree into for each worker
+const tasksPerWorker = 8
+
+// ParallelSolver splits a single search tree between several goroutines.
+// The top of the tree is split into subproblems, one for each consistent
+// assignment to the first few variables. Each worker keeps a queue of
+// subproblems and, once its own queue is empty, steals from the back of
+// another worker's queue, splitting what it steals again so that the rest
+// can be stolen in turn. Solve searches each subproblem with the Options
+// given, while Solutions and CountSolutions use chronological backtracking
+// without restarts, the only search that visits every solution once.
+type ParallelSolver[T comparable] struct {
+	State CSPState[T]
+	// Options options for the search run on each subproblem
+	Options SolverOptions
+	// Workers number of goroutines to use. Defaults to one per CPU.
+	Workers int
+	// Deterministic return the same results, in the same order, on every
+	// run. With a static variable ordering this is the order a sequential
+	// search finds them in. Solve may take longer, since it has to wait for
+	// the subproblems before the first solution found, and stolen
+	// subproblems aren't split again, since how a subproblem is searched
+	// mustn't depend on which worker stole it.
+	Deterministic bool
+}
+
+// NewParallelSolver create a parallel solver
+func NewParallelSolver[T comparable](vars Variables[T], constraints Constraints[T]) ParallelSolver[T] {
+	return ParallelSolver[T]{State: CSPState[T]{Vars: vars, Constraints: constraints, Propagations: []Propagation[T]{}}}
+}
+
+// task a subproblem: assignments to make before searching
+type task[T comparable] []taskAssignment[T]
+
+// taskAssignment an assignment to the variable at a position in Vars
+type taskAssignment[T comparable] struct {
+	variable int
+	value    T
+}
+
+// parallelTask a subproblem in a worker's queue. index is the position of
+// the subproblem it came from in the initial split, which is all that
+// Deterministic needs, since subproblems are only split again without it.
+type parallelTask[T comparable] struct {
+	index  int
+	prefix task[T]
+}
+
+// taskQueue a worker's double ended queue of subproblems
+type taskQueue[T comparable] struct {
+	lock  sync.Mutex
+	tasks []parallelTask[T]
+}
+
+// push add tasks to the back of the queue
+func (queue *taskQueue[T]) push(tasks ...parallelTask[T]) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	queue.tasks = append(queue.tasks, tasks...)
+}
+
+// popFront take the next task from the front of the queue
+func (queue *taskQueue[T]) popFront() (parallelTask[T], bool) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if len(queue.tasks) == 0 {
+		return parallelTask[T]{}, false
+	}
+	next := queue.tasks[0]
+	queue.tasks = queue.tasks[1:]
+	return next, true
+}
+
+// popBack steal a task from the back of the queue
+func (queue *taskQueue[T]) popBack() (parallelTask[T], bool) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if len(queue.tasks) == 0 {
+		return parallelTask[T]{}, false
+	}
+	last := queue.tasks[len(queue.tasks)-1]
+	queue.tasks = queue.tasks[:len(queue.tasks)-1]
+	return last, true
+}
+
+// Solve search for a single solution in parallel. On success the solution
+// is copied into State. If none is found because the search stopped at a
+// limit, such as SolverOptions.MaxDiscrepancies, ErrSearchLimit is returned.
+func (solver *ParallelSolver[T]) Solve(ctx context.Context) (bool, error) {
+	var lock sync.Mutex
+	var solution Variables[T]
+	best := -1
+	err := solver.run(ctx, false, func(index int, s *search[T], cancelAfter func(int)) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		if best < 0 || index < best {
+			best = index
+			solution = s.state.Vars.Copy()
+		}
+		if solver.Deterministic {
+			// only subproblems earlier in the tree can still do better
+			cancelAfter(index)
+		} else {
+			cancelAfter(-1)
+		}
+		return true
+	})
+	if err != nil {
+		return false, err
+	}
+	if best < 0 {
+		return false, nil
+	}
+	solver.State.Vars = solution
+	return true, nil
+}
+
+// Solutions find every solution in parallel. Unless Deterministic is set,
+// solutions are returned in the order they were found.
+func (solver *ParallelSolver[T]) Solutions(ctx context.Context) ([]Variables[T], error) {
+	var lock sync.Mutex
+	found := make(map[int][]Variables[T])
+	solutions := make([]Variables[T], 0)
+	err := solver.run(ctx, true, func(index int, s *search[T], cancelAfter func(int)) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		if solver.Deterministic {
+			found[index] = append(found[index], s.state.Vars.Copy())
+		} else {
+			solutions = append(solutions, s.state.Vars.Copy())
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if solver.Deterministic {
+		// subproblems are numbered in the order a sequential search visits them
+		for index := 0; len(found) > 0; index++ {
+			solutions = append(solutions, found[index]...)
+			delete(found, index)
+		}
+	}
+	return solutions, nil
+}
+
+// CountSolutions count every solution in parallel
+func (solver *ParallelSolver[T]) CountSolutions(ctx context.Context) (int, error) {
+	var lock sync.Mutex
+	count := 0
+	err := solver.run(ctx, true, func(index int, s *search[T], cancelAfter func(int)) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		count++
+		return false
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// run split the problem into subproblems and search them in parallel,
+// calling onSolution for the solution found in the subproblem at position
+// index, or if enumerate is set, for every solution. cancelAfter stops every
+// subproblem after the given position, or all of them for -1. Returns
+// ErrSearchLimit if a subproblem stopped at a limit and so either no
+// solution was found or, if enumerate is set, some may have been missed.
+func (solver *ParallelSolver[T]) run(ctx context.Context, enumerate bool, onSolution func(index int, s *search[T], cancelAfter func(int)) bool) error {
+	workers := solver.Workers
+	if workers <= 0 {
+		workers = defaultWorkers()
+	}
+
+	root := &BackTrackingCSPSolver[T]{State: solver.State.Copy(), Options: solver.Options}
+	rootSearch := newSearch(ctx, root)
+	if !rootSearch.state.allSatisfied() {
+		return nil
+	}
+	tasks := rootSearch.split(task[T]{}, workers*tasksPerWorker)
+
+	// hand out the subproblems round robin
+	queues := make([]*taskQueue[T], workers)
+	for w := range queues {
+		queues[w] = &taskQueue[T]{tasks: make([]parallelTask[T], 0)}
+	}
+	for index, prefix := range tasks {
+		queues[index%workers].push(parallelTask[T]{index, prefix})
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var lock sync.Mutex
+	// cutoff subproblems after this position are skipped or cancelled
+	cutoff := len(tasks)
+	// running the subproblems being searched, by a number unique to each,
+	// since those split from the same initial subproblem share its index
+	running := make(map[int]runningTask)
+	started := 0
+	// solved and limited whether any subproblem found a solution, or
+	// stopped at a limit
+	solved, limited := false, false
+	cancelAfter := func(index int) {
+		// called with the solution lock held, so take our own lock separately
+		lock.Lock()
+		defer lock.Unlock()
+		if index < 0 {
+			cancel()
+			return
+		}
+		if index < cutoff {
+			cutoff = index
+		}
+		for _, other := range running {
+			if other.index > index {
+				other.cancel()
+			}
+		}
+	}
+
+	var wait sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		worker := &BackTrackingCSPSolver[T]{State: solver.State.Copy(), Options: solver.Options}
+		wait.Add(1)
+		go func(w int, worker *BackTrackingCSPSolver[T]) {
+			defer wait.Done()
+			for {
+				next, ok := queues[w].popFront()
+				stolen := false
+				for steal := 1; !ok && steal < workers; steal++ {
+					next, ok = queues[(w+steal)%workers].popBack()
+					stolen = ok
+				}
+				if !ok || runCtx.Err() != nil {
+					return
+				}
+				if stolen && !solver.Deterministic {
+					// keep the first part and leave the rest to be stolen
+					parts := newSearch(runCtx, worker).split(next.prefix, workers)
+					if len(parts) == 0 {
+						continue
+					}
+					next.prefix = parts[0]
+					for _, part := range parts[1:] {
+						queues[w].push(parallelTask[T]{next.index, part})
+					}
+				}
+				index := next.index
+
+				lock.Lock()
+				if index > cutoff {
+					lock.Unlock()
+					continue
+				}
+				taskCtx, cancelTask := context.WithCancel(runCtx)
+				id := started
+				started++
+				running[id] = runningTask{index, cancelTask}
+				lock.Unlock()
+
+				if solver.Deterministic {
+					// what was learned depends on which subproblems this worker
+					// happened to get, so start each one from scratch
+					worker.nogoods, worker.heuristics = nil, nil
+				}
+				s := newSearch(taskCtx, worker)
+				if enumerate {
+					s.onSolution = func() bool {
+						return onSolution(index, s, cancelAfter)
+					}
+				}
+				found := s.runTask(next.prefix)
+				if found {
+					if !enumerate {
+						onSolution(index, s, cancelAfter)
+					}
+					// the search stopped at a solution without unwinding it
+					worker.State = solver.State.Copy()
+				}
+
+				lock.Lock()
+				delete(running, id)
+				solved = solved || found
+				limited = limited || s.limited
+				lock.Unlock()
+				cancelTask()
+			}
+		}(w, worker)
+	}
+	wait.Wait()
+
+	if ctx.Err() != nil {
+		return ErrExecutionCanceled
+	}
+	if limited && (enumerate || !solved) {
+		return ErrSearchLimit
+	}
+	return nil
+}
+
+// runningTask a subproblem being searched, which can be cancelled
+type runningTask struct {
+	index  int
+	cancel context.CancelFunc
+}
+
+// split divide the subtree below top into at least the given number of
+// subproblems (if there are that many), in the order the search would
+// visit them
+func (s *search[T]) split(top task[T], count int) []task[T] {
+	tasks := []task[T]{top}
+	for len(tasks) < count {
+		expanded := make([]task[T], 0, len(tasks))
+		progress := false
+		for _, prefix := range tasks {
+			removals := s.apply(prefix)
+			i := s.selectVariable()
+			if i < 0 {
+				// already a full solution
+				expanded = append(expanded, prefix)
+			} else {
+				progress = true
+				for _, option := range s.values(i) {
+					domainRemovals := s.assign(i, option)
+					if s.nogood(i) == nil && s.state.consistent(i) {
+						next := append(append(task[T]{}, prefix...), taskAssignment[T]{i, option})
+						expanded = append(expanded, next)
+					}
+					s.undo(i, domainRemovals)
+				}
+				s.state.Vars[i].Unset()
+			}
+			s.retract(prefix, removals)
+		}
+		tasks = expanded
+		if !progress {
+			break
+		}
+	}
+	return tasks
+}
+
+// apply make the assignments of a subproblem, returning what each one pruned
+func (s *search[T]) apply(prefix task[T]) []DomainRemovals[T] {
+	removals := make([]DomainRemovals[T], len(prefix))
+	for k, assignment := range prefix {
+		removals[k] = s.assign(assignment.variable, assignment.value)
+	}
+	return removals
+}
+
+// retract undo the assignments of a subproblem, most recent first
+func (s *search[T]) retract(prefix task[T], removals []DomainRemovals[T]) {
+	for k := len(prefix) - 1; k >= 0; k-- {
+		s.undo(prefix[k].variable, removals[k])
+		s.state.Vars[prefix[k].variable].Unset()
+	}
+}
+
+// runTask search the subproblem, restarting as the options say, then put
+// the state back how it was. If the search stops at a solution, it is left
+// in the state instead.
+func (s *search[T]) runTask(prefix task[T]) bool {
+	removals := s.apply(prefix)
+	solved := s.restarting()
+	if !solved {
+		s.retract(prefix, removals)
+	}
+	return solved
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSolutions(t *testing.T) {
+	vars, constraints := queensProblem(6)
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	solutions, err := solver.Solutions(context.TODO())
+	assert.Nil(t, err)
+	assert.Len(t, solutions, 4)
+	for _, solution := range solutions {
+		assert.True(t, solution.Complete())
+		assert.True(t, constraints.AllSatisfied(&solution))
+	}
+
+	vars, constraints = queensProblem(8)
+	solver = NewBackTrackingCSPSolver(vars, constraints)
+	count, err := solver.CountSolutions(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 92, count)
+}
+
+func TestParallelSolver(t *testing.T) {
+	vars, constraints := queensProblem(8)
+	solver := NewParallelSolver(vars, constraints)
+	solver.Workers = 4
+	count, err := solver.CountSolutions(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 92, count)
+
+	solver = NewParallelSolver(vars, constraints)
+	solver.Workers = 4
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.True(t, solver.State.Vars.Complete())
+	assert.True(t, constraints.AllSatisfied(&solver.State.Vars))
+
+	// no solution: every subproblem fails
+	solver = NewParallelSolver(Variables[int]{
+		NewVariable("A", IntRange(0, 2)),
+		NewVariable("B", IntRange(0, 2)),
+		NewVariable("C", IntRange(0, 2)),
+	}, AllUnique[int]("A", "B", "C"))
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, success)
+}
+
+func TestParallelSolverDeterministic(t *testing.T) {
+	vars, constraints := queensProblem(8)
+	sequential := NewBackTrackingCSPSolver(vars, constraints)
+	expected, err := sequential.Solutions(context.TODO())
+	assert.Nil(t, err)
+
+	// the same solutions in the same order as a sequential search, every time
+	for run := 0; run < 3; run++ {
+		solver := NewParallelSolver(vars, constraints)
+		solver.Workers = 4
+		solver.Deterministic = true
+		solutions, err := solver.Solutions(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, expected, solutions)
+
+		solver = NewParallelSolver(vars, constraints)
+		solver.Workers = 4
+		solver.Deterministic = true
+		success, err := solver.Solve(context.TODO())
+		assert.Nil(t, err)
+		assert.True(t, success)
+		assert.Equal(t, expected[0], solver.State.Vars)
+	}
+}
+
+func TestParallelSolverOptions(t *testing.T) {
+	// whatever the first variables are, Y and Z have to go against the
+	// default value order, which takes two discrepancies. A single worker
+	// never steals, so the subproblems only fix W and X.
+	vars := Variables[int]{
+		NewVariable("W", IntRange(0, 4)),
+		NewVariable("X", IntRange(0, 4)),
+		NewVariable("Y", IntRange(0, 2)),
+		NewVariable("Z", IntRange(0, 2)),
+	}
+	constraints := Constraints[int]{UnaryEquals[int]("Y", 1), UnaryEquals[int]("Z", 1)}
+	solver := NewParallelSolver(vars, constraints)
+	solver.Workers = 1
+	solver.Options.Search = LimitedDiscrepancySearch
+	solver.Options.MaxDiscrepancies = 1
+	success, err := solver.Solve(context.TODO())
+	assert.ErrorIs(t, err, ErrSearchLimit)
+	assert.False(t, success)
+
+	solver.Options.MaxDiscrepancies = 2
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+
+	// enumerating ignores the search strategy
+	solver = NewParallelSolver(vars, constraints)
+	solver.Workers = 2
+	solver.Options.Search = LimitedDiscrepancySearch
+	solver.Options.MaxDiscrepancies = 1
+	count, err := solver.CountSolutions(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 16, count)
+}
+
+func TestSplit(t *testing.T) {
+	vars, constraints := queensProblem(6)
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	s := newSearch(context.TODO(), &solver)
+	// with the first queen in the corner, the second has four places left
+	parts := s.split(task[int]{{0, 0}}, 2)
+	assert.Equal(t, []task[int]{
+		{{0, 0}, {1, 2}},
+		{{0, 0}, {1, 3}},
+		{{0, 0}, {1, 4}},
+		{{0, 0}, {1, 5}},
+	}, parts)
+	assert.Equal(t, 6, solver.State.Vars.Unassigned())
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// PortfolioSolver races several differently configured backtracking
+// searches against each other, each on its own copy of the problem and in
+// its own goroutine. The first to finish wins and the rest are cancelled.
+type PortfolioSolver[T comparable] struct {
+	State CSPState[T]
+	// Portfolio options for each of the searches to run
+	Portfolio []SolverOptions
+	// Winner position in Portfolio of the search that finished first, or -1
+	Winner int
+}
+
+// NewPortfolioSolver create a portfolio solver. If no options are given,
+// DefaultPortfolio is used with one search per CPU.
+func NewPortfolioSolver[T comparable](vars Variables[T], constraints Constraints[T], portfolio ...SolverOptions) PortfolioSolver[T] {
+	return PortfolioSolver[T]{State: CSPState[T]{Vars: vars, Constraints: constraints, Propagations: []Propagation[T]{}},
+		Portfolio: portfolio, Winner: -1}
+}
+
+// DefaultPortfolio a mix of n search configurations: each adaptive variable
+// ordering heuristic in turn, with randomized restarts on different seeds.
+func DefaultPortfolio(n int) []SolverOptions {
+	orderings := []VariableOrdering{DomOverWeightedDegree, ActivityBased, MinimumRemainingValues}
+	schedules := []RestartSchedule{LubyRestarts, GeometricRestarts}
+	portfolio := make([]SolverOptions, n)
+	for i := range portfolio {
+		portfolio[i] = SolverOptions{
+			VariableOrdering: orderings[i%len(orderings)],
+			Restarts:         schedules[(i/len(orderings))%len(schedules)],
+			Randomize:        i > 0,
+			Seed:             int64(i),
+		}
+	}
+	return portfolio
+}
+
+// Solve run every search in the portfolio concurrently. On success the
+// winning assignment is copied into State. If every search stopped at a
+// limit, such as SolverOptions.MaxDiscrepancies, without finding a
+// solution, ErrSearchLimit is returned.
+func (solver *PortfolioSolver[T]) Solve(ctx context.Context) (bool, error) {
+	portfolio := solver.Portfolio
+	if len(portfolio) == 0 {
+		portfolio = DefaultPortfolio(defaultWorkers())
+	}
+	solver.Winner = -1
+
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var lock sync.Mutex
+	var wait sync.WaitGroup
+	solved := false
+	racers := make([]*BackTrackingCSPSolver[T], len(portfolio))
+	for i := range portfolio {
+		racers[i] = &BackTrackingCSPSolver[T]{State: solver.State.Copy(), Options: portfolio[i]}
+	}
+	for i, racer := range racers {
+		wait.Add(1)
+		go func(i int, racer *BackTrackingCSPSolver[T]) {
+			defer wait.Done()
+			s := newSearch(raceCtx, racer)
+			success := s.run()
+			lock.Lock()
+			defer lock.Unlock()
+			if solver.Winner >= 0 || raceCtx.Err() != nil {
+				return
+			}
+			if success || racer.Options.complete() || !s.limited {
+				// either a solution, or a search that skipped nothing has
+				// proven there is none
+				solver.Winner, solved = i, success
+				if success {
+					solver.State.Vars = racer.State.Vars
+				}
+				cancel()
+			}
+		}(i, racer)
+	}
+	wait.Wait()
+
+	if solver.Winner < 0 {
+		if ctx.Err() != nil {
+			return false, ErrExecutionCanceled
+		}
+		return false, ErrSearchLimit
+	}
+	return solved, nil
+}
+
+// complete whether a search with these options is guaranteed to find a
+// solution if there is one
+func (options *SolverOptions) complete() bool {
+	return options.Search != LimitedDiscrepancySearch || options.MaxDiscrepancies <= 0
+}
+
+// defaultWorkers number of goroutines to use when none is specified
+func defaultWorkers() int {
+	return runtime.GOMAXPROCS(0)
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPortfolioSolver(t *testing.T) {
+	vars, constraints := queensProblem(20)
+	solver := NewPortfolioSolver(vars, constraints, DefaultPortfolio(4)...)
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+	assert.GreaterOrEqual(t, solver.Winner, 0)
+	assert.True(t, solver.State.Constraints.AllSatisfied(&solver.State.Vars))
+	assert.True(t, solver.State.Vars.Complete())
+
+	// the original variables are left alone
+	assert.Equal(t, 20, vars.Unassigned())
+
+	// a complete search proves an unsatisfiable problem has no solution
+	solver = NewPortfolioSolver(Variables[int]{
+		NewVariable("A", IntRange(0, 2)),
+		NewVariable("B", IntRange(0, 2)),
+		NewVariable("C", IntRange(0, 2)),
+	}, AllUnique[int]("A", "B", "C"),
+		SolverOptions{Search: LimitedDiscrepancySearch, MaxDiscrepancies: 1},
+		SolverOptions{Search: ConflictDirectedBackjumping})
+	success, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, success)
+	assert.Equal(t, 1, solver.Winner)
+
+	// with only incomplete searches, giving up isn't a proof
+	solver = NewPortfolioSolver(Variables[int]{
+		NewVariable("Y", IntRange(0, 2)),
+		NewVariable("Z", IntRange(0, 2)),
+	}, Constraints[int]{UnaryEquals[int]("Y", 1), UnaryEquals[int]("Z", 1)},
+		SolverOptions{Search: LimitedDiscrepancySearch, MaxDiscrepancies: 1})
+	success, err = solver.Solve(context.TODO())
+	assert.ErrorIs(t, err, ErrSearchLimit)
+	assert.False(t, success)
+	assert.Equal(t, -1, solver.Winner)
+}
+
+func TestPortfolioTimeout(t *testing.T) {
+	// eleven pigeons in ten holes: far too big to finish
+	vars := make(Variables[int], 0)
+	names := make(VariableNames, 0)
+	for i := 0; i < 11; i++ {
+		name := VariableName(fmt.Sprintf("P%d", i))
+		vars = append(vars, NewVariable(name, IntRange(0, 10)))
+		names = append(names, name)
+	}
+	constraints := AllUnique[int](names...)
+
+	solver := NewPortfolioSolver(vars, constraints)
+	ctx, cancel := context.WithTimeout(context.TODO(), 20*time.Millisecond)
+	defer cancel()
+	success, err := solver.Solve(ctx)
+	assert.Equal(t, ErrExecutionCanceled, err)
+	assert.False(t, success)
+	assert.Equal(t, -1, solver.Winner)
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import "fmt"
+
+// queensProblem place n queens on an n x n board so that none attack each
+// other. Variable Qi is the row of the queen in column i.
+func queensProblem(n int) (Variables[int], Constraints[int]) {
+	vars := make(Variables[int], 0)
+	names := make(VariableNames, 0)
+	for i := 0; i < n; i++ {
+		name := VariableName(fmt.Sprintf("Q%d", i))
+		vars = append(vars, NewVariable(name, IntRange(0, n)))
+		names = append(names, name)
+	}
+	constraints := AllUnique[int](names...)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			distance := j - i
+			constraints = append(constraints, NewIndexedConstraint(VariableNames{names[i], names[j]}, func(variables *Variables[int], indices []int) bool {
+				a, b := variables.At(indices[0]), variables.At(indices[1])
+				if a.Empty || b.Empty {
+					return true
+				}
+				return a.Value-b.Value != distance && b.Value-a.Value != distance
+			}))
+		}
+	}
+	return vars, constraints
+}
+
+// pigeonholeProblem six free variables followed by three pigeons that must
+// sit in different holes, but there are only two holes. Every branch of the
+// free variables fails in exactly the same way.
+func pigeonholeProblem(evaluations *int) (Variables[int], Constraints[int]) {
+	vars := make(Variables[int], 0)
+	for i := 0; i < 6; i++ {
+		vars = append(vars, NewVariable(VariableName(fmt.Sprintf("X%d", i)), IntRange(0, 2)))
+	}
+	vars = append(vars,
+		NewVariable("P", IntRange(0, 2)),
+		NewVariable("Q", IntRange(0, 2)),
+		NewVariable("R", IntRange(0, 2)))
+
+	constraints := make(Constraints[int], 0)
+	for _, constraint := range AllUnique[int]("P", "Q", "R") {
+		notEquals := constraint
+		constraints = append(constraints, Constraint[int]{Vars: notEquals.Vars, ConstraintFunction: func(variables *Variables[int]) bool {
+			*evaluations++
+			return notEquals.ConstraintFunction(variables)
+		}})
+	}
+	return vars, constraints
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Pruning why a value was removed from a variable's domain by
+// MakeArcConsistent or SimplifyPreAssignment
+type Pruning[T comparable] struct {
+	// Variable the variable whose domain the value was removed from
+	Variable VariableName
+	Value    T
+	// Constraint the constraint that ruled the value out
+	Constraint Constraint[T]
+	// Assignments assigned variables the constraint was checked against
+	Assignments []VariableAssignment[T]
+	// Removals values already removed from the domains of the other
+	// variables in the constraint, which would otherwise have allowed this
+	// value. Each removed in the same run can be explained in turn with
+	// Explain.
+	Removals []VariableAssignment[T]
+}
+
+// String describe the pruning in words
+func (pruning Pruning[T]) String() string {
+	reasons := make([]string, 0, len(pruning.Assignments)+len(pruning.Removals))
+	for _, assignment := range pruning.Assignments {
+		reasons = append(reasons, fmt.Sprintf("%v is set to %v", assignment.VariableName, assignment.Value))
+	}
+	for _, removal := range pruning.Removals {
+		reasons = append(reasons, fmt.Sprintf("%v can't be %v", removal.VariableName, removal.Value))
+	}
+	description := fmt.Sprintf("%v can't be %v because of %v", pruning.Variable, pruning.Value, pruning.Constraint.describe())
+	if len(reasons) == 0 {
+		return description
+	}
+	return description + ", given that " + strings.Join(reasons, " and ")
+}
+
+// provenance the reason for every value pruned from the domains of a CSPState
+type provenance[T comparable] struct {
+	reasons map[VariableAssignment[T]]Pruning[T]
+	// removed values pruned from each variable, in the order they were pruned
+	removed map[VariableName][]T
+}
+
+// Explain find out why a value is missing from a variable's domain, if it
+// was removed by the last run of MakeArcConsistent or SimplifyPreAssignment.
+// Returns false if it wasn't. Each run starts afresh, since the domains or
+// constraints may have changed since the one before.
+func (state *CSPState[T]) Explain(name VariableName, value T) (Pruning[T], bool) {
+	if state.provenance == nil {
+		return Pruning[T]{}, false
+	}
+	pruning, ok := state.provenance.reasons[VariableAssignment[T]{name, value}]
+	return pruning, ok
+}
+
+// recordPruning remember why a value was removed from a domain. Only the
+// first reason found for each value is kept.
+func (state *CSPState[T]) recordPruning(pruning Pruning[T]) {
+	if state.provenance == nil {
+		state.provenance = &provenance[T]{reasons: make(map[VariableAssignment[T]]Pruning[T]), removed: make(map[VariableName][]T)}
+	}
+	key := VariableAssignment[T]{pruning.Variable, pruning.Value}
+	if _, ok := state.provenance.reasons[key]; ok {
+		return
+	}
+	state.provenance.reasons[key] = pruning
+	state.provenance.removed[pruning.Variable] = append(state.provenance.removed[pruning.Variable], pruning.Value)
+}
+
+// removedValues values pruned so far from the named variable's domain:
+// those of this run in the order they were pruned, then any pruned before
+func (state *CSPState[T]) removedValues(name VariableName) []T {
+	var removed []T
+	if state.provenance != nil {
+		removed = append(removed, state.provenance.removed[name]...)
+	}
+	if state.unpruned == nil {
+		return removed
+	}
+	variable := state.find(name)
+	for _, value := range state.unpruned.domains[name] {
+		if !variable.Domain.Contains(value) && !slices.Contains(removed, value) {
+			removed = append(removed, value)
+		}
+	}
+	return removed
+}
+
+// unpruned the domains of a CSPState's variables as they were before local
+// consistency first pruned them, for UnsatCore
+type unpruned[T comparable] struct {
+	domains map[VariableName]Domain[T]
+	// derived values assigned by SimplifyPreAssignment rather than up front
+	derived map[VariableName]T
+}
+
+// keepUnpruned remember the domain of each variable not seen before, ahead
+// of pruning
+func (state *CSPState[T]) keepUnpruned() {
+	if state.unpruned == nil {
+		state.unpruned = &unpruned[T]{domains: make(map[VariableName]Domain[T]), derived: make(map[VariableName]T)}
+	}
+	for _, variable := range state.Vars {
+		if _, ok := state.unpruned.domains[variable.Name]; !ok {
+			state.unpruned.domains[variable.Name] = append(Domain[T]{}, variable.Domain...)
+		}
+	}
+}
+
+// unprunedVariable the variable with its domain as it was before pruning,
+// and unassigned if SimplifyPreAssignment assigned it
+func (state *CSPState[T]) unprunedVariable(variable Variable[T]) Variable[T] {
+	if state.unpruned == nil {
+		return variable
+	}
+	if domain, ok := state.unpruned.domains[variable.Name]; ok {
+		variable.Domain = append(Domain[T]{}, domain...)
+	}
+	if value, ok := state.unpruned.derived[variable.Name]; ok && !variable.Empty && variable.Value == value {
+		variable.Unset()
+	}
+	return variable
+}
+
+// copy a copy of the unpruned domains that can be added to independently
+func (u *unpruned[T]) copy() *unpruned[T] {
+	copied := &unpruned[T]{domains: make(map[VariableName]Domain[T], len(u.domains)), derived: make(map[VariableName]T, len(u.derived))}
+	for name, domain := range u.domains {
+		copied.domains[name] = domain
+	}
+	for name, value := range u.derived {
+		copied.derived[name] = value
+	}
+	return copied
+}
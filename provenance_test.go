@@ -0,0 +1,107 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplainArcConsistency(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("X", IntRange(0, 3)),
+		NewVariable("Y", IntRange(0, 3)),
+		NewVariable("Z", IntRange(0, 3)),
+	}
+	constraints := Constraints[int]{
+		LessThan[int]("X", "Y"),
+		LessThan[int]("Y", "Z"),
+	}
+	state := CSPState[int]{Vars: vars, Constraints: constraints}
+	assert.Nil(t, state.MakeArcConsistent(context.TODO()))
+	assert.Equal(t, Domain[int]{0}, state.Vars.Find("X").Domain)
+	assert.Equal(t, Domain[int]{1}, state.Vars.Find("Y").Domain)
+	assert.Equal(t, Domain[int]{2}, state.Vars.Find("Z").Domain)
+
+	// nothing at all in Y's domain is less than 0
+	pruning, ok := state.Explain("Y", 0)
+	assert.True(t, ok)
+	assert.Equal(t, "X < Y", pruning.Constraint.Name)
+	assert.Empty(t, pruning.Removals)
+	assert.Equal(t, "Y can't be 0 because of X < Y", pruning.String())
+
+	// X = 1 was allowed by Y = 2, until that was ruled out by Y < Z
+	pruning, ok = state.Explain("X", 1)
+	assert.True(t, ok)
+	assert.Equal(t, []VariableAssignment[int]{{"Y", 2}}, pruning.Removals)
+	assert.Equal(t, "X can't be 1 because of X < Y, given that Y can't be 2", pruning.String())
+	pruning, ok = state.Explain("Y", 2)
+	assert.True(t, ok)
+	assert.Equal(t, "Y < Z", pruning.Constraint.Name)
+
+	// still in the domain
+	_, ok = state.Explain("X", 0)
+	assert.False(t, ok)
+}
+
+func TestExplainPreAssignment(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(0, 3)),
+		NewVariable("B", IntRange(0, 3)),
+	}
+	vars.SetValue("A", 1)
+	state := CSPState[int]{Vars: vars, Constraints: Constraints[int]{NotEquals[int]("A", "B")}}
+	assert.Nil(t, state.SimplifyPreAssignment(context.TODO()))
+	assert.Equal(t, Domain[int]{0, 2}, state.Vars.Find("B").Domain)
+
+	pruning, ok := state.Explain("B", 1)
+	assert.True(t, ok)
+	assert.Equal(t, []VariableAssignment[int]{{"A", 1}}, pruning.Assignments)
+	assert.Equal(t, "B can't be 1 because of A != B, given that A is set to 1", pruning.String())
+}
+
+func TestExplainAcrossRuns(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(0, 3)),
+		NewVariable("B", IntRange(0, 3)),
+		NewVariable("C", IntRange(0, 3)),
+	}
+	vars.SetValue("A", 1)
+	state := CSPState[int]{Vars: vars, Constraints: Constraints[int]{NotEquals[int]("A", "B"), Equals[int]("B", "C")}}
+	assert.Nil(t, state.SimplifyPreAssignment(context.TODO()))
+	assert.Nil(t, state.MakeArcConsistent(context.TODO()))
+	assert.Equal(t, Domain[int]{0, 2}, state.Vars.Find("C").Domain)
+
+	// the value pruned by the earlier run is still given as a reason, but
+	// only this run's prunings are explained
+	pruning, ok := state.Explain("C", 1)
+	assert.True(t, ok)
+	assert.Equal(t, []VariableAssignment[int]{{"B", 1}}, pruning.Removals)
+	_, ok = state.Explain("B", 1)
+	assert.False(t, ok)
+
+	// with the domains back and a different constraint, C = 1 is allowed
+	state.Vars.Find("B").SetDomain(IntRange(0, 3))
+	state.Vars.Find("C").SetDomain(IntRange(0, 3))
+	state.Constraints = Constraints[int]{LessThan[int]("B", "C")}
+	assert.Nil(t, state.MakeArcConsistent(context.TODO()))
+	_, ok = state.Explain("C", 1)
+	assert.False(t, ok)
+	pruning, ok = state.Explain("C", 0)
+	assert.True(t, ok)
+	assert.Equal(t, "B < C", pruning.Constraint.Name)
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"fmt"
+)
+
+// RepairResult outcome of Repair
+type RepairResult[T comparable] struct {
+	// Solved whether an assignment satisfying every hard constraint was found
+	Solved bool
+	// Optimal whether the search finished, proving no solution changes fewer variables
+	Optimal bool
+	// Changed the variables whose values differ from the previous assignment
+	Changed VariableNames
+}
+
+// HintsFrom the values of the assigned variables, for use as Hints
+func HintsFrom[T comparable](variables Variables[T]) map[VariableName]T {
+	hints := make(map[VariableName]T, len(variables))
+	for _, variable := range variables {
+		if !variable.Empty {
+			hints[variable.Name] = variable.Value
+		}
+	}
+	return hints
+}
+
+// Repair find the solution that changes as few variables as possible from a
+// previous assignment, e.g. after a constraint has been added. Each variable
+// that is unassigned in State but assigned in previous gets a soft
+// constraint of weight 1 to keep its old value, and SolveMaxCSP minimises
+// the number broken, trying the old values first. These count alongside any
+// other soft constraints, at WeakPriority. Variables in previous that are no
+// longer in State are ignored. The solution is left in State.
+func (solver *BackTrackingCSPSolver[T]) Repair(ctx context.Context, previous Variables[T]) (RepairResult[T], error) {
+	constraints, hints := solver.State.Constraints, solver.Hints
+	defer func() {
+		solver.State.Constraints, solver.Hints = constraints, hints
+		// the learned weights of the real constraints are kept
+		if solver.heuristics != nil && len(solver.heuristics.weights) > len(constraints) {
+			solver.heuristics.weights = solver.heuristics.weights[:len(constraints)]
+		}
+	}()
+	solver.State.Constraints = append(Constraints[T]{}, constraints...)
+	solver.Hints = HintsFrom(previous)
+	for name, value := range hints {
+		solver.Hints[name] = value
+	}
+	for _, variable := range previous {
+		current := solver.State.Vars.IndexOf(variable.Name)
+		if variable.Empty || current < 0 || !solver.State.Vars[current].Empty {
+			continue
+		}
+		keep := named(fmt.Sprintf("%v stays %v", variable.Name, variable.Value), UnaryEquals[T](variable.Name, variable.Value))
+		solver.State.Constraints = append(solver.State.Constraints, SoftConstraint(keep, 1))
+		if solver.heuristics != nil && len(solver.heuristics.weights) == len(solver.State.Constraints)-1 {
+			solver.heuristics.weights = append(solver.heuristics.weights, 1)
+		}
+	}
+
+	soft, err := solver.SolveMaxCSP(ctx)
+	result := RepairResult[T]{Solved: soft.Solved, Optimal: soft.Optimal, Changed: VariableNames{}}
+	if err != nil || !soft.Solved {
+		return result, err
+	}
+	for _, variable := range previous {
+		current := solver.State.Vars.IndexOf(variable.Name)
+		if !variable.Empty && current >= 0 && solver.State.Vars[current].Value != variable.Value {
+			result.Changed = append(result.Changed, variable.Name)
+		}
+	}
+	return result, nil
+}
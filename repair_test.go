@@ -0,0 +1,103 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHints(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(1, 4)),
+		NewVariable("B", IntRange(1, 4)),
+		NewVariable("C", IntRange(1, 4)),
+	}
+	solver := NewBackTrackingCSPSolver(vars, AllUnique[int]("A", "B", "C"))
+	// a hint outside the domain is ignored
+	solver.Hints = map[VariableName]int{"A": 3, "C": 2, "B": 7}
+	solved, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, solved)
+	assert.Equal(t, []int{3, 1, 2}, values(solver.State.Vars))
+
+	// hints are only a preference: nothing is left for B above 3, or below A
+	vars = Variables[int]{
+		NewVariable("A", IntRange(1, 4)),
+		NewVariable("B", IntRange(1, 4)),
+	}
+	solver = NewBackTrackingCSPSolver(vars, Constraints[int]{LessThan[int]("A", "B")})
+	solver.Hints = HintsFrom(Variables[int]{{Name: "A", Value: 3}, {Name: "B", Value: 1}})
+	solved, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, solved)
+	assert.Equal(t, []int{1, 2}, values(solver.State.Vars))
+}
+
+func TestRepair(t *testing.T) {
+	// four tasks, each in its own time slot
+	schedule := func() BackTrackingCSPSolver[int] {
+		vars := Variables[int]{
+			NewVariable("T1", IntRange(1, 5)),
+			NewVariable("T2", IntRange(1, 5)),
+			NewVariable("T3", IntRange(1, 5)),
+			NewVariable("T4", IntRange(1, 5)),
+		}
+		return NewBackTrackingCSPSolver(vars, AllUnique[int]("T1", "T2", "T3", "T4"))
+	}
+	solver := schedule()
+	solved, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, solved)
+	previous := solver.State.Vars.Copy()
+	assert.Equal(t, []int{1, 2, 3, 4}, values(previous))
+
+	// T1 can no longer go in the first two slots. Solving from scratch
+	// moves three tasks...
+	late := Constraint[int]{Vars: VariableNames{"T1"}, ConstraintFunction: func(variables *Variables[int]) bool {
+		t1 := variables.Find("T1")
+		return t1.Empty || t1.Value > 2
+	}}
+	solver = schedule()
+	solver.State.Constraints = append(solver.State.Constraints, late)
+	solved, err = solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, solved)
+	assert.Equal(t, []int{3, 1, 2, 4}, values(solver.State.Vars))
+
+	// ...but repairing the old schedule only swaps two
+	solver = schedule()
+	solver.State.Constraints = append(solver.State.Constraints, late)
+	result, err := solver.Repair(context.TODO(), previous)
+	assert.Nil(t, err)
+	assert.True(t, result.Solved)
+	assert.True(t, result.Optimal)
+	assert.Equal(t, VariableNames{"T1", "T3"}, result.Changed)
+	assert.Equal(t, []int{3, 2, 1, 4}, values(solver.State.Vars))
+	// the soft constraints were only temporary
+	assert.Len(t, solver.State.Constraints, 7)
+	assert.Nil(t, solver.Hints)
+
+	// variables that have since been removed are ignored
+	solver = schedule()
+	previous = append(previous, Variable[int]{Name: "T5", Value: 5})
+	result, err = solver.Repair(context.TODO(), previous)
+	assert.Nil(t, err)
+	assert.True(t, result.Solved)
+	assert.Empty(t, result.Changed)
+	assert.Equal(t, []int{1, 2, 3, 4}, values(solver.State.Vars))
+}
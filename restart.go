@@ -0,0 +1,86 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import "math"
+
+const (
+	// DefaultRestartScale cutoff of the first run when SolverOptions.RestartScale is not set
+	DefaultRestartScale = 100
+	// DefaultRestartFactor growth of geometric cutoffs when SolverOptions.RestartFactor is not set
+	DefaultRestartFactor = 1.5
+)
+
+// RestartSchedule sequence of cutoffs after which the search gives up on
+// its current run and starts again from the top of the tree. Restarts pay
+// off when combined with SolverOptions.Randomize or an adaptive heuristic,
+// since each run then explores a different part of the tree.
+type RestartSchedule int
+
+const (
+	// NoRestarts search until a solution is found or the tree is exhausted
+	NoRestarts RestartSchedule = iota
+	// LubyRestarts cutoffs follow the Luby sequence 1, 1, 2, 1, 1, 2, 4, 1, ...
+	// multiplied by the restart scale
+	LubyRestarts
+	// GeometricRestarts cutoffs start at the restart scale and grow by the
+	// restart factor after every run
+	GeometricRestarts
+)
+
+// RestartLimit what is counted against the cutoff of each run
+type RestartLimit int
+
+const (
+	// FailLimit count assignments rejected by a constraint or nogood
+	FailLimit RestartLimit = iota
+	// NodeLimit count every assignment made
+	NodeLimit
+)
+
+// cutoff limit for the given run (starting at 1), or 0 for no limit
+func (options *SolverOptions) cutoff(run int) int {
+	scale := options.RestartScale
+	if scale <= 0 {
+		scale = DefaultRestartScale
+	}
+	switch options.Restarts {
+	case LubyRestarts:
+		return scale * luby(run)
+	case GeometricRestarts:
+		factor := options.RestartFactor
+		if factor <= 1 {
+			factor = DefaultRestartFactor
+		}
+		return int(float64(scale) * math.Pow(factor, float64(run-1)))
+	default:
+		return 0
+	}
+}
+
+// luby i-th term (starting at 1) of the Luby sequence
+func luby(i int) int {
+	for {
+		// find the smallest k such that 2^k - 1 >= i
+		k := 1
+		for (1<<k)-1 < i {
+			k++
+		}
+		if i == (1<<k)-1 {
+			return 1 << (k - 1)
+		}
+		i = i - (1 << (k - 1)) + 1
+	}
+}
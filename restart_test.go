@@ -0,0 +1,69 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestartSchedules(t *testing.T) {
+	sequence := make([]int, 0)
+	for i := 1; i <= 15; i++ {
+		sequence = append(sequence, luby(i))
+	}
+	assert.Equal(t, []int{1, 1, 2, 1, 1, 2, 4, 1, 1, 2, 1, 1, 2, 4, 8}, sequence)
+
+	options := SolverOptions{Restarts: LubyRestarts, RestartScale: 10}
+	assert.Equal(t, 10, options.cutoff(1))
+	assert.Equal(t, 40, options.cutoff(7))
+
+	options = SolverOptions{Restarts: GeometricRestarts, RestartScale: 10, RestartFactor: 2}
+	assert.Equal(t, 10, options.cutoff(1))
+	assert.Equal(t, 80, options.cutoff(4))
+
+	options = SolverOptions{}
+	assert.Equal(t, 0, options.cutoff(5))
+}
+
+func TestRandomizedRestarts(t *testing.T) {
+	solve := func(seed int64) (Variables[int], string) {
+		vars, constraints := queensProblem(10)
+		var buffer bytes.Buffer
+		solver := NewBackTrackingCSPSolver(vars, constraints)
+		solver.State.Logger = slog.New(slog.NewTextHandler(&buffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		solver.Options.VariableOrdering = MinimumRemainingValues
+		solver.Options.Randomize = true
+		solver.Options.Seed = seed
+		solver.Options.Restarts = LubyRestarts
+		solver.Options.RestartScale = 2
+		success, err := solver.Solve(context.TODO())
+		assert.Nil(t, err)
+		assert.True(t, success)
+		assert.True(t, solver.State.Constraints.AllSatisfied(&solver.State.Vars))
+		return solver.State.Vars, buffer.String()
+	}
+
+	solution, output := solve(42)
+	assert.Contains(t, output, "msg=restart")
+
+	// the same seed always gives the same answer
+	again, _ := solve(42)
+	assert.Equal(t, solution, again)
+}
@@ -0,0 +1,468 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// SearchStrategy algorithm used by BackTrackingCSPSolver to explore the search tree
+type SearchStrategy int
+
+const (
+	// ChronologicalBacktracking on failure, undo the most recent assignment
+	// and try its next value. This is the default.
+	ChronologicalBacktracking SearchStrategy = iota
+	// ConflictDirectedBackjumping on failure, jump straight back to the most
+	// recently assigned variable that took part in the conflict, skipping
+	// assignments that had nothing to do with it.
+	ConflictDirectedBackjumping
+	// LimitedDiscrepancySearch trust the value ordering: first search only the
+	// path that always takes each variable's first value, then the paths that
+	// deviate from it a little, and so on. Taking a variable's second value
+	// costs one discrepancy, its third value two, etc. The number of
+	// discrepancies allowed goes up by one for every pass over the tree.
+	LimitedDiscrepancySearch
+)
+
+// varSet set of variables by their position in Vars
+type varSet map[int]struct{}
+
+// search state of a single run of the backtracking search over a CSPState
+type search[T comparable] struct {
+	state   *CSPState[T]
+	options *SolverOptions
+	nogoods *nogoodDatabase[T]
+	done    <-chan struct{}
+	// aborted set once the search has been told to stop early
+	aborted bool
+	// pruners for each variable, the variables whose propagations have
+	// removed values from its domain
+	pruners []varSet
+	// heuristics learned weights and activities for variable ordering
+	heuristics *heuristicState
+	// random source of random tie-breaking, nil unless Randomize is set
+	random *rand.Rand
+	// nodes number of assignments made
+	nodes int
+	// fails number of assignments rejected by a constraint or nogood
+	fails int
+	// backtracks number of variables unassigned after their values ran out
+	backtracks int
+	// propagations number of assignments the Propagations were run for
+	propagations int
+	// removals number of values removed from domains by propagation
+	removals int
+	// depth number of variables assigned by the search so far, and the most there have been
+	depth, maxDepth int
+	// propagating time spent running the Propagations
+	propagating time.Duration
+	// cutoff limit on nodes or fails for the current run, 0 if unlimited
+	cutoff int
+	// runStart nodes or fails counted before the current run began
+	runStart int
+	// restart set when the current run reached its cutoff
+	restart bool
+	// limited set when a pass of limited discrepancy search skipped a value
+	limited bool
+	// onSolution when set, called for every solution found instead of
+	// stopping at the first. The search stops if it returns true.
+	onSolution func() bool
+	// soft when set, the cost of the soft constraints violated so far
+	soft *softCost
+	// bound when set, called before going down a level. Returning false
+	// prunes the branch, e.g. because it can't beat the best solution found.
+	bound func() bool
+	// hints value to try first for the variable at each position, if any
+	hints map[int]T
+}
+
+func newSearch[T comparable](ctx context.Context, solver *BackTrackingCSPSolver[T]) *search[T] {
+	state := &solver.State
+	state.compile()
+	if solver.Options.LearnNogoods && solver.nogoods == nil {
+		solver.nogoods = newNogoodDatabase[T]()
+	}
+	pruners := make([]varSet, len(state.Vars))
+	for i := range pruners {
+		pruners[i] = make(varSet)
+	}
+	if solver.heuristics == nil {
+		solver.heuristics = &heuristicState{}
+	}
+	solver.heuristics.fit(len(state.Vars), len(state.Constraints), solver.generation)
+	s := &search[T]{state: state, options: &solver.Options, nogoods: solver.nogoods, heuristics: solver.heuristics,
+		done: ctx.Done(), pruners: pruners}
+	if solver.Options.Randomize {
+		s.random = rand.New(rand.NewSource(solver.Options.Seed))
+	}
+	s.hints = solver.hints()
+	return s
+}
+
+// run search for a single solution
+func (s *search[T]) run() bool {
+	// check the initial assignment in full once. After this, only the
+	// constraints on each newly assigned variable need to be checked.
+	if !s.state.allSatisfied() {
+		return false
+	}
+	return s.restarting()
+}
+
+// restarting search from the current state, starting over each time a run
+// reaches its cutoff
+func (s *search[T]) restarting() bool {
+	for run := 1; ; run++ {
+		if s.onSolution == nil {
+			// restarting would find the same solutions again when enumerating
+			s.cutoff = s.options.cutoff(run)
+		}
+		s.runStart = s.counted()
+		solved := s.searchOnce()
+		if solved || !s.restart {
+			return solved
+		}
+		// the run reached its cutoff. everything learned so far is kept.
+		s.aborted, s.restart = false, false
+		if s.state.index.debug {
+			s.state.trace("restart", "run", run+1, "nodes", s.nodes, "fails", s.fails)
+		}
+	}
+}
+
+// searchOnce a single run of the search from the top of the tree
+func (s *search[T]) searchOnce() bool {
+	if s.onSolution != nil {
+		// only chronological backtracking visits every solution exactly once
+		return s.reduce()
+	}
+	if s.options.Search == LimitedDiscrepancySearch {
+		for allowed := 0; s.options.MaxDiscrepancies <= 0 || allowed <= s.options.MaxDiscrepancies; allowed++ {
+			s.limited = false
+			if s.discrepancy(allowed) {
+				return true
+			}
+			if s.aborted || !s.limited {
+				// nothing was skipped, so the whole tree has been searched
+				return false
+			}
+			if s.state.index.debug {
+				s.state.trace("discrepancies", "allowed", allowed+1)
+			}
+		}
+		return false
+	}
+	// learning nogoods needs the conflict sets computed while backjumping
+	if s.options.Search == ConflictDirectedBackjumping || s.options.LearnNogoods {
+		solved, _ := s.backjump()
+		return solved
+	}
+	return s.reduce()
+}
+
+// counted nodes or fails so far, whichever the restart cutoff applies to
+func (s *search[T]) counted() int {
+	if s.options.RestartLimit == NodeLimit {
+		return s.nodes
+	}
+	return s.fails
+}
+
+// stop check whether the search should give up, either because the context
+// is done or because the current run has reached its cutoff
+func (s *search[T]) stop() bool {
+	if s.aborted {
+		return true
+	}
+	if s.cutoff > 0 && s.counted()-s.runStart >= s.cutoff {
+		s.aborted, s.restart = true, true
+		return true
+	}
+	select {
+	case <-s.done:
+		s.aborted = true
+	default:
+	}
+	return s.aborted
+}
+
+// assign set the variable at position i and propagate the assignment.
+// Returns the domain removals that were applied.
+func (s *search[T]) assign(i int, value T) DomainRemovals[T] {
+	state := s.state
+	variable := &state.Vars[i]
+	variable.SetValue(value)
+	s.nodes++
+	s.depth++
+	if s.depth > s.maxDepth {
+		s.maxDepth = s.depth
+	}
+	if state.index.debug {
+		state.trace("assign", "variable", variable.Name, "value", value)
+	}
+	if len(state.Propagations) == 0 {
+		return nil
+	}
+
+	// get the propagations and apply them to the rest of the variables
+	start := time.Now()
+	domainRemovals := state.Propagations.Execute(VariableAssignment[T]{variable.Name, value}, &state.Vars)
+	domainRemovals = evaluateDomainRemovals(domainRemovals, state.find)
+	s.propagating += time.Since(start)
+	s.propagations++
+	s.removals += len(domainRemovals)
+	for _, removal := range domainRemovals {
+		s.pruners[state.index.vars[removal.VariableName]][i] = struct{}{}
+		if state.index.debug {
+			state.trace("prune", "variable", removal.VariableName, "value", removal.Value, "cause", variable.Name)
+		}
+	}
+	return domainRemovals
+}
+
+// undo reverse the propagation of an assignment to the variable at position i
+func (s *search[T]) undo(i int, domainRemovals DomainRemovals[T]) {
+	s.depth--
+	resetDomainRemovalEvaluation(domainRemovals, s.state.find)
+	for _, removal := range domainRemovals {
+		delete(s.pruners[s.state.index.vars[removal.VariableName]], i)
+	}
+}
+
+// unassign unset the variable at position i after all of its values have been tried
+func (s *search[T]) unassign(i int) {
+	s.state.Vars[i].Unset()
+	s.backtracks++
+	if s.state.index.debug {
+		s.state.trace("backtrack", "variable", s.state.Vars[i].Name)
+	}
+}
+
+// nogood find a known nogood broken by the assignment to the variable at position i
+func (s *search[T]) nogood(i int) Nogood[T] {
+	if s.nogoods == nil || len(s.nogoods.nogoods) == 0 {
+		return nil
+	}
+	variable := &s.state.Vars[i]
+	return s.nogoods.violated(VariableAssignment[T]{variable.Name, variable.Value}, s.state)
+}
+
+// learn record the current assignments of the given variables as a nogood
+func (s *search[T]) learn(conflicts varSet) {
+	if !s.options.LearnNogoods {
+		return
+	}
+	nogood := make(Nogood[T], 0, len(conflicts))
+	for j := range conflicts {
+		variable := &s.state.Vars[j]
+		nogood = append(nogood, VariableAssignment[T]{variable.Name, variable.Value})
+	}
+	if s.nogoods.add(nogood, s.options.nogoodLimit(), s.options.NogoodEviction) && s.state.index.debug {
+		s.state.trace("learn", "nogood", nogood)
+	}
+}
+
+// solution report a full solution. Returns true if the search should stop.
+func (s *search[T]) solution() bool {
+	if s.onSolution == nil {
+		return true
+	}
+	return s.onSolution()
+}
+
+// complete whether the full assignment satisfies every hard constraint,
+// counting a failure if not
+func (s *search[T]) complete() bool {
+	if s.state.hardSatisfied() {
+		return true
+	}
+	s.fails++
+	return false
+}
+
+// assigned every assigned variable, by position
+func (s *search[T]) assigned() varSet {
+	assigned := make(varSet)
+	for j := range s.state.Vars {
+		if !s.state.Vars[j].Empty {
+			assigned[j] = struct{}{}
+		}
+	}
+	return assigned
+}
+
+// bounded whether the current branch could still beat the best solution
+func (s *search[T]) bounded() bool {
+	if s.bound == nil || s.bound() {
+		return true
+	}
+	s.fails++
+	return false
+}
+
+// reduce implements chronological backtracking search
+func (s *search[T]) reduce() bool {
+	if s.stop() {
+		return false
+	}
+	i := s.selectVariable()
+	if i < 0 {
+		// every variable is assigned and consistent: we have a full solution
+		return s.complete() && s.solution()
+	}
+
+	// iterate over options in the domain
+	for _, option := range s.values(i) {
+		domainRemovals := s.assign(i, option)
+		// only constraints on this variable can have become unsatisfied.
+		// if they hold, go down a level to assign to another variable
+		if nogood := s.nogood(i); nogood != nil {
+			s.failed(i, -1, nogood)
+		} else if violated := s.state.violated(i); violated >= 0 {
+			s.failed(i, violated, nil)
+		} else {
+			penalized := s.penalize(i)
+			if s.bounded() && s.reduce() {
+				return true
+			}
+			s.unpenalize(penalized)
+		}
+		s.undo(i, domainRemovals)
+		if s.aborted {
+			break
+		}
+	}
+	// no value works given the assignments above this one
+	s.unassign(i)
+	return false
+}
+
+// discrepancy implements a single pass of limited discrepancy search, trying
+// only the values that keep the total discrepancies within allowed
+func (s *search[T]) discrepancy(allowed int) bool {
+	if s.stop() {
+		return false
+	}
+	i := s.selectVariable()
+	if i < 0 {
+		return s.complete()
+	}
+
+	for position, option := range s.values(i) {
+		if position > allowed {
+			s.limited = true
+			break
+		}
+		domainRemovals := s.assign(i, option)
+		if nogood := s.nogood(i); nogood != nil {
+			s.failed(i, -1, nogood)
+		} else if violated := s.state.violated(i); violated >= 0 {
+			s.failed(i, violated, nil)
+		} else if s.discrepancy(allowed - position) {
+			return true
+		}
+		s.undo(i, domainRemovals)
+		if s.aborted {
+			break
+		}
+	}
+	s.unassign(i)
+	return false
+}
+
+// backjump implements conflict-directed backjumping. On failure, returns
+// the conflict set: the assigned variables responsible for the failure.
+// When only learning nogoods, the conflict sets are computed the same way
+// but the search still backtracks chronologically.
+func (s *search[T]) backjump() (bool, varSet) {
+	if s.stop() {
+		return false, nil
+	}
+	i := s.selectVariable()
+	if i < 0 {
+		if !s.complete() {
+			// a constraint that doesn't list its variables could be down to any of them
+			return false, s.assigned()
+		}
+		return true, nil
+	}
+
+	// values pruned from the domain by propagation are conflicts too
+	conflicts := make(varSet)
+	for j := range s.pruners[i] {
+		conflicts[j] = struct{}{}
+	}
+
+	for _, option := range s.values(i) {
+		domainRemovals := s.assign(i, option)
+		if nogood := s.nogood(i); nogood != nil {
+			// a known nogood already rules out this value
+			for _, assignment := range nogood {
+				if j := s.state.index.vars[assignment.VariableName]; j != i {
+					conflicts[j] = struct{}{}
+				}
+			}
+			s.failed(i, -1, nogood)
+			s.undo(i, domainRemovals)
+			continue
+		}
+		if violated := s.state.violated(i); violated >= 0 {
+			// every other assigned variable in the violated constraint is to
+			// blame, or every one at all if the constraint has no Vars
+			blamed := s.state.index.constraints[violated]
+			if len(blamed) == 0 {
+				blamed = make([]int, len(s.state.Vars))
+				for j := range blamed {
+					blamed[j] = j
+				}
+			}
+			for _, j := range blamed {
+				if j != i && !s.state.Vars[j].Empty {
+					conflicts[j] = struct{}{}
+				}
+			}
+			s.failed(i, violated, nil)
+			s.undo(i, domainRemovals)
+			continue
+		}
+		solved, childConflicts := s.backjump()
+		if solved {
+			return true, nil
+		}
+		s.undo(i, domainRemovals)
+		if s.aborted {
+			break
+		}
+		if _, ok := childConflicts[i]; !ok && s.options.Search == ConflictDirectedBackjumping {
+			// this variable played no part in the failure below it, so
+			// trying its other values is pointless. jump back past it.
+			s.unassign(i)
+			return false, childConflicts
+		}
+		for j := range childConflicts {
+			if j != i {
+				conflicts[j] = struct{}{}
+			}
+		}
+	}
+	if !s.aborted {
+		s.learn(conflicts)
+	}
+	s.unassign(i)
+	return false, conflicts
+}
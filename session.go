@@ -0,0 +1,386 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotAllowed returned when a decision conflicts with the constraints
+	// and the decisions already made
+	ErrNotAllowed error = errors.New("value not allowed by the current decisions")
+)
+
+// Session interactive configurator over a CSPState. The user makes
+// decisions one at a time, and after each one the consequences are
+// propagated through the constraints until nothing more can be pruned, so
+// the domains in State.Vars only hold values that are still possible.
+// Decisions can be retracted in any order, and undone and redone.
+type Session[T comparable] struct {
+	state *CSPState[T]
+	// original variables as they were before the session began
+	original Variables[T]
+	// history every step so far, with the current one at position current
+	history []sessionStep[T]
+	current int
+}
+
+// sessionStep the decisions made and what they left of the domains
+type sessionStep[T comparable] struct {
+	decisions  []VariableAssignment[T]
+	vars       Variables[T]
+	provenance *provenance[T]
+}
+
+// NewSession start a configurator session. Variables already assigned are
+// fixed for the whole session. Returns ErrEmptyDomain or ErrNotAllowed if
+// the problem is inconsistent to begin with.
+func (state *CSPState[T]) NewSession() (*Session[T], error) {
+	state.compile()
+	state.keepUnpruned()
+	session := &Session[T]{state: state, original: state.Vars.Copy()}
+	if err := session.rebuild(nil); err != nil {
+		state.Vars = session.original.Copy()
+		return nil, err
+	}
+	session.history = []sessionStep[T]{session.snapshot(nil)}
+	return session, nil
+}
+
+// Decisions the decisions currently in effect, in the order they were made
+func (session *Session[T]) Decisions() []VariableAssignment[T] {
+	return append([]VariableAssignment[T]{}, session.history[session.current].decisions...)
+}
+
+// Domains the values still possible for each variable. A variable that has
+// been decided or fixed has only its value.
+func (session *Session[T]) Domains() map[VariableName]Domain[T] {
+	domains := make(map[VariableName]Domain[T], len(session.state.Vars))
+	for _, variable := range session.state.Vars {
+		if variable.Empty {
+			domains[variable.Name] = append(Domain[T]{}, variable.Domain...)
+		} else {
+			domains[variable.Name] = Domain[T]{variable.Value}
+		}
+	}
+	return domains
+}
+
+// Assign decide the value of a variable, replacing any earlier decision for
+// it, and propagate. If the value has already been ruled out, returns
+// ErrNotAllowed and changes nothing; State.Explain says why.
+func (session *Session[T]) Assign(name VariableName, value T) (map[VariableName]Domain[T], error) {
+	state := session.state
+	i, ok := state.index.vars[name]
+	if !ok {
+		panic(fmt.Sprintf("Variable not found by name %v in variables %v", name, state.Vars))
+	}
+	decisions := session.Decisions()
+	for k, decision := range decisions {
+		if decision.VariableName == name {
+			if decision.Value == value {
+				return session.Domains(), nil
+			}
+			// changing a decision: start again without the old one
+			decisions = append(decisions[:k], decisions[k+1:]...)
+			if err := session.rebuild(decisions); err != nil {
+				session.restore()
+				return nil, err
+			}
+			break
+		}
+	}
+	variable := &state.Vars[i]
+	if !variable.Empty || !variable.Domain.Contains(value) {
+		// fixed before the session began, or already ruled out
+		fixed := !variable.Empty && variable.Value == value
+		session.restore()
+		if fixed {
+			return session.Domains(), nil
+		}
+		return nil, ErrNotAllowed
+	}
+	if err := session.decide(i, value); err != nil {
+		session.restore()
+		return nil, err
+	}
+	session.push(append(decisions, VariableAssignment[T]{name, value}))
+	return session.Domains(), nil
+}
+
+// Retract take back the decision for a variable and propagate what's left.
+// Does nothing if no decision has been made for it.
+func (session *Session[T]) Retract(name VariableName) (map[VariableName]Domain[T], error) {
+	decisions := session.Decisions()
+	for k, decision := range decisions {
+		if decision.VariableName == name {
+			decisions = append(decisions[:k], decisions[k+1:]...)
+			if err := session.rebuild(decisions); err != nil {
+				session.restore()
+				return nil, err
+			}
+			session.push(decisions)
+			break
+		}
+	}
+	return session.Domains(), nil
+}
+
+// Undo go back to before the last Assign or Retract. Returns false if there
+// is nothing to undo.
+func (session *Session[T]) Undo() (map[VariableName]Domain[T], bool) {
+	if session.current == 0 {
+		return session.Domains(), false
+	}
+	session.current--
+	session.restore()
+	return session.Domains(), true
+}
+
+// Redo repeat the last step undone. Returns false if there is nothing to redo.
+func (session *Session[T]) Redo() (map[VariableName]Domain[T], bool) {
+	if session.current == len(session.history)-1 {
+		return session.Domains(), false
+	}
+	session.current++
+	session.restore()
+	return session.Domains(), true
+}
+
+// push record a new step, dropping anything that could have been redone
+func (session *Session[T]) push(decisions []VariableAssignment[T]) {
+	session.history = append(session.history[:session.current+1], session.snapshot(decisions))
+	session.current++
+}
+
+// snapshot copy of the current domains and their provenance
+func (session *Session[T]) snapshot(decisions []VariableAssignment[T]) sessionStep[T] {
+	step := sessionStep[T]{decisions: decisions, vars: session.state.Vars.Copy()}
+	if session.state.provenance != nil {
+		step.provenance = session.state.provenance.copy()
+	}
+	return step
+}
+
+// restore put the state back to the current step
+func (session *Session[T]) restore() {
+	step := &session.history[session.current]
+	session.state.Vars = step.vars.Copy()
+	session.state.provenance = nil
+	if step.provenance != nil {
+		session.state.provenance = step.provenance.copy()
+	}
+}
+
+// rebuild propagate the given decisions from the original variables
+func (session *Session[T]) rebuild(decisions []VariableAssignment[T]) error {
+	state := session.state
+	state.Vars = session.original.Copy()
+	state.provenance = nil
+	queue := make([]int, len(state.Constraints))
+	for c := range queue {
+		queue[c] = c
+	}
+	if err := state.propagate(queue); err != nil {
+		return err
+	}
+	for _, decision := range decisions {
+		i := state.index.vars[decision.VariableName]
+		if !state.Vars[i].Domain.Contains(decision.Value) {
+			return ErrNotAllowed
+		}
+		if err := session.decide(i, decision.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decide assign the variable at position i and propagate to a fixpoint
+func (session *Session[T]) decide(i int, value T) error {
+	state := session.state
+	variable := &state.Vars[i]
+	variable.SetValue(value)
+	if state.index.debug {
+		state.trace("decide", "variable", variable.Name, "value", value)
+	}
+	queue := append([]int{}, state.index.incident[i]...)
+	removals := state.Propagations.Execute(VariableAssignment[T]{variable.Name, value}, &state.Vars)
+	for _, removal := range evaluateDomainRemovals(removals, state.find) {
+		j := state.index.vars[removal.VariableName]
+		if len(state.Vars[j].Domain) == 0 {
+			return ErrEmptyDomain
+		}
+		queue = append(queue, state.index.incident[j]...)
+	}
+	return state.propagate(queue)
+}
+
+// propagate enforce arc consistency on binary constraints, and forward
+// checking on the rest, starting from the constraints at the given positions
+// and continuing until no more values can be pruned
+func (state *CSPState[T]) propagate(queue []int) error {
+	queued := make([]bool, len(state.Constraints))
+	for _, c := range queue {
+		queued[c] = true
+	}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		queued[c] = false
+		constraint := state.Constraints[c]
+		if constraint.Soft {
+			continue
+		}
+		changed, err := state.revise(c)
+		if err != nil {
+			return err
+		}
+		for _, i := range changed {
+			for _, other := range state.index.incident[i] {
+				if other != c && !queued[other] {
+					queued[other] = true
+					queue = append(queue, other)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// revise prune the domains of the unassigned variables of the constraint at
+// position c, keeping only values that some combination of values of the
+// other unassigned variables allows. Returns the positions of the variables
+// whose domains changed.
+func (state *CSPState[T]) revise(c int) ([]int, error) {
+	constraint := state.Constraints[c]
+	unassigned := make([]int, 0, len(constraint.Vars))
+	assignments := make([]VariableAssignment[T], 0, len(constraint.Vars))
+	for _, i := range state.index.constraints[c] {
+		variable := &state.Vars[i]
+		if containsIndex(unassigned, i) {
+			continue
+		}
+		if variable.Empty {
+			unassigned = append(unassigned, i)
+		} else {
+			assignments = append(assignments, VariableAssignment[T]{variable.Name, variable.Value})
+		}
+	}
+	if len(unassigned) == 0 {
+		if !state.satisfied(c) {
+			return nil, ErrNotAllowed
+		}
+		return nil, nil
+	}
+	if len(unassigned) == 2 && len(constraint.Vars) == 2 {
+		// binary constraints keep the more detailed explanations of arcReduce
+		return state.reviseArcs(c, unassigned)
+	}
+	changed := make([]int, 0)
+	for k, i := range unassigned {
+		others := append(append([]int{}, unassigned[:k]...), unassigned[k+1:]...)
+		variable := &state.Vars[i]
+		domain := make(Domain[T], 0, len(variable.Domain))
+		for _, value := range variable.Domain {
+			variable.SetValue(value)
+			if state.supported(c, others) {
+				domain = append(domain, value)
+				continue
+			}
+			pruning := Pruning[T]{Variable: variable.Name, Value: value, Constraint: constraint, Assignments: assignments}
+			for _, j := range others {
+				for _, removed := range state.removedValues(state.Vars[j].Name) {
+					pruning.Removals = append(pruning.Removals, VariableAssignment[T]{state.Vars[j].Name, removed})
+				}
+			}
+			state.recordPruning(pruning)
+		}
+		variable.Unset()
+		if len(domain) == 0 {
+			return nil, ErrEmptyDomain
+		}
+		if len(domain) < len(variable.Domain) {
+			variable.SetDomain(domain)
+			changed = append(changed, i)
+		}
+	}
+	return changed, nil
+}
+
+// reviseArcs make the binary constraint at position c arc consistent both
+// ways between the two unassigned variables at the given positions
+func (state *CSPState[T]) reviseArcs(c int, unassigned []int) ([]int, error) {
+	changed := make([]int, 0, 2)
+	for k, i := range unassigned {
+		other := unassigned[1-k]
+		change, domain := arcReduce(state.Vars[i].Name, state.Vars[other].Name, state.Constraints[c], state)
+		if len(domain) == 0 {
+			return nil, ErrEmptyDomain
+		}
+		if change {
+			state.Vars[i].SetDomain(domain)
+			changed = append(changed, i)
+		}
+	}
+	return changed, nil
+}
+
+// supported whether the constraint at position c can be satisfied by giving
+// the unassigned variables at the given positions values from their domains.
+// Constraints are checked as each variable is assigned, so combinations are
+// cut short as soon as they are ruled out. The variables are left unassigned.
+func (state *CSPState[T]) supported(c int, unassigned []int) bool {
+	if !state.satisfied(c) {
+		return false
+	}
+	if len(unassigned) == 0 {
+		return true
+	}
+	variable := &state.Vars[unassigned[0]]
+	defer variable.Unset()
+	for _, value := range variable.Domain {
+		variable.SetValue(value)
+		if state.supported(c, unassigned[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsIndex slice contains method for positions
+func containsIndex(indices []int, index int) bool {
+	for _, item := range indices {
+		if item == index {
+			return true
+		}
+	}
+	return false
+}
+
+// copy a copy of the provenance that can be added to independently
+func (p *provenance[T]) copy() *provenance[T] {
+	copied := &provenance[T]{reasons: make(map[VariableAssignment[T]]Pruning[T], len(p.reasons)),
+		removed: make(map[VariableName][]T, len(p.removed))}
+	for key, pruning := range p.reasons {
+		copied.reasons[key] = pruning
+	}
+	for name, values := range p.removed {
+		copied.removed[name] = append([]T{}, values...)
+	}
+	return copied
+}
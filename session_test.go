@@ -0,0 +1,183 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	// a small product configurator: the engine, gearbox and trim level of a
+	// car, with rules about which go together
+	vars := Variables[string]{
+		NewVariable("Engine", Domain[string]{"petrol", "diesel", "electric"}),
+		NewVariable("Gearbox", Domain[string]{"manual", "automatic"}),
+		NewVariable("Trim", Domain[string]{"base", "sport", "luxury"}),
+		NewVariable("Roof", Domain[string]{"fixed", "sunroof", "convertible"}),
+	}
+	constraints := Constraints[string]{
+		// electric cars only come with an automatic gearbox
+		{Vars: VariableNames{"Engine", "Gearbox"}, Name: "electric cars are automatic", ConstraintFunction: func(variables *Variables[string]) bool {
+			engine, gearbox := variables.Find("Engine"), variables.Find("Gearbox")
+			return engine.Empty || gearbox.Empty || engine.Value != "electric" || gearbox.Value == "automatic"
+		}},
+		// the sport trim isn't offered with a diesel engine
+		{Vars: VariableNames{"Engine", "Trim"}, Name: "no diesel sport", ConstraintFunction: func(variables *Variables[string]) bool {
+			engine, trim := variables.Find("Engine"), variables.Find("Trim")
+			return engine.Empty || trim.Empty || engine.Value != "diesel" || trim.Value != "sport"
+		}},
+		// a convertible needs the sport trim and a manual gearbox
+		{Vars: VariableNames{"Trim", "Gearbox", "Roof"}, Name: "convertibles are manual sports", ConstraintFunction: func(variables *Variables[string]) bool {
+			trim, gearbox, roof := variables.Find("Trim"), variables.Find("Gearbox"), variables.Find("Roof")
+			return trim.Empty || gearbox.Empty || roof.Empty || roof.Value != "convertible" ||
+				(trim.Value == "sport" && gearbox.Value == "manual")
+		}},
+	}
+
+	state := CSPState[string]{Vars: vars.Copy(), Constraints: constraints}
+	session, err := state.NewSession()
+	assert.Nil(t, err)
+	assert.Equal(t, Domain[string]{"manual", "automatic"}, session.Domains()["Gearbox"])
+
+	domains, err := session.Assign("Engine", "electric")
+	assert.Nil(t, err)
+	assert.Equal(t, Domain[string]{"electric"}, domains["Engine"])
+	assert.Equal(t, Domain[string]{"automatic"}, domains["Gearbox"])
+	assert.Equal(t, Domain[string]{"base", "sport", "luxury"}, domains["Trim"])
+	pruning, ok := state.Explain("Gearbox", "manual")
+	assert.True(t, ok)
+	assert.Equal(t, "Gearbox can't be manual because of electric cars are automatic, given that Engine is set to electric", pruning.String())
+
+	// the three-way rule applies once both trim and gearbox are known
+	domains, err = session.Assign("Trim", "luxury")
+	assert.Nil(t, err)
+	assert.Equal(t, Domain[string]{"fixed", "sunroof"}, domains["Roof"])
+
+	// the domains live in the state, ready to be solved
+	assert.Equal(t, Domain[string]{"fixed", "sunroof"}, state.Vars.Find("Roof").Domain)
+	assert.Equal(t, []VariableAssignment[string]{{"Engine", "electric"}, {"Trim", "luxury"}}, session.Decisions())
+
+	// a value already ruled out is refused, and nothing changes
+	_, err = session.Assign("Roof", "convertible")
+	assert.ErrorIs(t, err, ErrNotAllowed)
+	assert.Equal(t, domains, session.Domains())
+	assert.Len(t, session.Decisions(), 2)
+
+	// retracting a decision keeps the later ones
+	state = CSPState[string]{Vars: vars.Copy(), Constraints: constraints}
+	session, err = state.NewSession()
+	assert.Nil(t, err)
+	_, err = session.Assign("Roof", "convertible")
+	assert.Nil(t, err)
+	domains, err = session.Assign("Trim", "sport")
+	assert.Nil(t, err)
+	assert.Equal(t, Domain[string]{"manual"}, domains["Gearbox"])
+	assert.Equal(t, Domain[string]{"petrol"}, domains["Engine"])
+
+	// taking back the first decision frees the gearbox but keeps the trim
+	domains, err = session.Retract("Roof")
+	assert.Nil(t, err)
+	assert.Equal(t, Domain[string]{"manual", "automatic"}, domains["Gearbox"])
+	assert.Equal(t, Domain[string]{"petrol", "electric"}, domains["Engine"])
+	assert.Equal(t, Domain[string]{"sport"}, domains["Trim"])
+	assert.Equal(t, []VariableAssignment[string]{{"Trim", "sport"}}, session.Decisions())
+
+	// changing a decision replaces it
+	domains, err = session.Assign("Trim", "base")
+	assert.Nil(t, err)
+	assert.Equal(t, Domain[string]{"petrol", "diesel", "electric"}, domains["Engine"])
+	assert.Equal(t, []VariableAssignment[string]{{"Trim", "base"}}, session.Decisions())
+
+	// undo and redo step through the decisions
+	state = CSPState[string]{Vars: vars.Copy(), Constraints: constraints}
+	session, err = state.NewSession()
+	assert.Nil(t, err)
+	initial := session.Domains()
+	_, ok = session.Undo()
+	assert.False(t, ok)
+
+	afterEngine, err := session.Assign("Engine", "diesel")
+	assert.Nil(t, err)
+	afterRoof, err := session.Assign("Roof", "fixed")
+	assert.Nil(t, err)
+
+	domains, ok = session.Undo()
+	assert.True(t, ok)
+	assert.Equal(t, afterEngine, domains)
+	domains, ok = session.Undo()
+	assert.True(t, ok)
+	assert.Equal(t, initial, domains)
+	assert.Empty(t, session.Decisions())
+	_, ok = state.Explain("Trim", "sport")
+	assert.False(t, ok)
+
+	domains, ok = session.Redo()
+	assert.True(t, ok)
+	assert.Equal(t, afterEngine, domains)
+	_, ok = state.Explain("Trim", "sport")
+	assert.True(t, ok)
+
+	// a new decision clears what could have been redone
+	_, err = session.Assign("Gearbox", "manual")
+	assert.Nil(t, err)
+	_, ok = session.Redo()
+	assert.False(t, ok)
+	assert.Equal(t, []VariableAssignment[string]{{"Engine", "diesel"}, {"Gearbox", "manual"}}, session.Decisions())
+	assert.NotEqual(t, afterRoof, session.Domains())
+}
+
+func TestSessionInconsistent(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(1, 4)),
+		NewVariable("B", IntRange(1, 4)),
+		NewVariable("C", IntRange(1, 4)),
+	}
+	// every variable must differ from the others, and A must be below B
+	constraints := AllUnique[int]("A", "B", "C")
+	constraints = append(constraints, LessThan[int]("A", "B"))
+	state := CSPState[int]{Vars: vars, Constraints: constraints}
+	session, err := state.NewSession()
+	assert.Nil(t, err)
+	assert.Equal(t, Domain[int]{1, 2}, session.Domains()["A"])
+
+	// with C at 1, A and B are left with only {2} and {3}
+	domains, err := session.Assign("C", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, Domain[int]{2}, domains["A"])
+	assert.Equal(t, Domain[int]{3}, domains["B"])
+
+	// B at 2 leaves A only 1, so C can't be 1 any more
+	_, err = session.Retract("C")
+	assert.Nil(t, err)
+	domains, err = session.Assign("B", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, Domain[int]{1}, domains["A"])
+	assert.Equal(t, Domain[int]{3}, domains["C"])
+	_, err = session.Assign("C", 1)
+	assert.ErrorIs(t, err, ErrNotAllowed)
+	pruning, ok := state.Explain("C", 1)
+	assert.True(t, ok)
+	assert.Equal(t, "C can't be 1 because of A != C, given that A can't be 3 and A can't be 2", pruning.String())
+	assert.Equal(t, []VariableAssignment[int]{{"B", 2}}, session.Decisions())
+
+	// the problem can't start out inconsistent
+	state = CSPState[int]{Vars: Variables[int]{NewVariable("A", IntRange(1, 4)), NewVariable("B", IntRange(1, 4))},
+		Constraints: Constraints[int]{LessThan[int]("A", "B"), GreaterThan[int]("A", "B")}}
+	_, err = state.NewSession()
+	assert.ErrorIs(t, err, ErrEmptyDomain)
+	assert.Equal(t, Domain[int]{1, 2, 3}, state.Vars.Find("A").Domain)
+}
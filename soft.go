@@ -0,0 +1,247 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"sort"
+)
+
+// Priority level of a soft constraint in a constraint hierarchy. Required
+// constraints are the hard ones, which are not Soft.
+type Priority int
+
+const (
+	// WeakPriority the lowest level. This is the default.
+	WeakPriority Priority = iota
+	// MediumPriority preferred over WeakPriority
+	MediumPriority
+	// StrongPriority preferred over MediumPriority
+	StrongPriority
+)
+
+// SoftConstraint mark a constraint as soft, with the given penalty for violating it
+func SoftConstraint[T comparable](constraint Constraint[T], weight float64) Constraint[T] {
+	constraint.Soft, constraint.Weight = true, weight
+	return constraint
+}
+
+// PreferredConstraint mark a constraint as soft, at the given level of the
+// constraint hierarchy and with the given penalty for violating it
+func PreferredConstraint[T comparable](constraint Constraint[T], priority Priority, weight float64) Constraint[T] {
+	constraint = SoftConstraint(constraint, weight)
+	constraint.Priority = priority
+	return constraint
+}
+
+// SoftConstraints mark every constraint in a collection as soft, with the
+// given penalty for violating each of them
+func SoftConstraints[T comparable](constraints Constraints[T], weight float64) Constraints[T] {
+	soft := make(Constraints[T], len(constraints))
+	for i, constraint := range constraints {
+		soft[i] = SoftConstraint(constraint, weight)
+	}
+	return soft
+}
+
+// SoftResult outcome of SolveMaxCSP
+type SoftResult[T comparable] struct {
+	// Solved whether an assignment satisfying every hard constraint was found
+	Solved bool
+	// Optimal whether the search finished, proving no assignment has a lower Cost
+	Optimal bool
+	// Cost total Weight of the soft constraints violated
+	Cost float64
+	// Violated the soft constraints violated by the assignment
+	Violated Constraints[T]
+	// Levels how well each level of the constraint hierarchy is satisfied,
+	// highest priority first
+	Levels []LevelResult
+}
+
+// LevelResult how well one level of a constraint hierarchy is satisfied
+type LevelResult struct {
+	Priority Priority
+	// Cost total Weight of the soft constraints violated at this level
+	Cost float64
+	// Satisfied number of soft constraints at this level that hold
+	Satisfied int
+	// Violated number of soft constraints at this level that don't
+	Violated int
+}
+
+// softCost the soft constraints violated by the current partial assignment
+type softCost struct {
+	// violated whether each constraint, by position, is counted in costs
+	violated []bool
+	// level position in costs of the priority of each constraint
+	level []int
+	// costs weight violated at each priority, highest first
+	costs []float64
+	// bound costs of the best solution found so far, nil if none has been
+	bound []float64
+}
+
+// newSoftCost set up the cost of the soft constraints in the given state
+func newSoftCost[T comparable](state *CSPState[T]) *softCost {
+	cost := &softCost{violated: make([]bool, len(state.Constraints)), level: make([]int, len(state.Constraints))}
+	priorities := state.priorities()
+	for c, constraint := range state.Constraints {
+		if !constraint.Soft {
+			continue
+		}
+		cost.level[c] = sort.Search(len(priorities), func(k int) bool { return priorities[k] <= constraint.Priority })
+	}
+	cost.costs = make([]float64, len(priorities))
+	return cost
+}
+
+// add count the constraint at position c as violated
+func (cost *softCost) add(c int, weight float64) {
+	cost.violated[c] = true
+	cost.costs[cost.level[c]] += weight
+}
+
+// remove stop counting the constraint at position c as violated
+func (cost *softCost) remove(c int, weight float64) {
+	cost.violated[c] = false
+	cost.costs[cost.level[c]] -= weight
+}
+
+// improves whether the current costs are lexicographically lower than the bound
+func (cost *softCost) improves() bool {
+	if cost.bound == nil {
+		return true
+	}
+	for k := range cost.costs {
+		if cost.costs[k] != cost.bound[k] {
+			return cost.costs[k] < cost.bound[k]
+		}
+	}
+	return false
+}
+
+// zero whether nothing is violated
+func (cost *softCost) zero() bool {
+	for _, c := range cost.costs {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// priorities distinct priorities of the soft constraints, highest first
+func (state *CSPState[T]) priorities() []Priority {
+	priorities := make([]Priority, 0)
+	for _, constraint := range state.Constraints {
+		if !constraint.Soft {
+			continue
+		}
+		k := sort.Search(len(priorities), func(k int) bool { return priorities[k] <= constraint.Priority })
+		if k == len(priorities) || priorities[k] != constraint.Priority {
+			priorities = append(priorities, 0)
+			copy(priorities[k+1:], priorities[k:])
+			priorities[k] = constraint.Priority
+		}
+	}
+	return priorities
+}
+
+// SolveMaxCSP find the assignment that satisfies every hard constraint and
+// minimises the total Weight of the soft constraints it violates, using
+// branch and bound on top of chronological backtracking. If the soft
+// constraints have different priorities, the violated weight at each level
+// is minimised before any lower level is considered. The best assignment
+// is left in State. If ctx finishes after a solution has been found, the
+// best one so far is kept and no error is returned, but it is not marked
+// Optimal.
+func (solver *BackTrackingCSPSolver[T]) SolveMaxCSP(ctx context.Context) (SoftResult[T], error) {
+	var best Variables[T]
+	// the search checks ctx itself, so it can run on this goroutine
+	s := newSearch(ctx, solver)
+	s.soft = newSoftCost(s.state)
+	// soft constraints already broken by the variables assigned up front
+	for c := range s.state.Constraints {
+		if s.state.Constraints[c].Soft && !s.state.satisfied(c) {
+			s.soft.add(c, s.state.Constraints[c].weight())
+		}
+	}
+	s.bound = s.soft.improves
+	s.onSolution = func() bool {
+		// anything reaching here is cheaper than the bound
+		best, s.soft.bound = s.state.Vars.Copy(), append([]float64{}, s.soft.costs...)
+		if s.state.index.debug {
+			s.state.trace("solution", "cost", s.soft.costs)
+		}
+		// nothing can beat a solution that violates nothing
+		return s.soft.zero()
+	}
+	s.run()
+
+	result := SoftResult[T]{}
+	if best == nil {
+		if s.aborted {
+			return result, ErrExecutionCanceled
+		}
+		return result, nil
+	}
+	solver.State.Vars = best
+	result.Solved, result.Optimal = true, !s.aborted
+	result.Levels = make([]LevelResult, len(s.soft.costs))
+	for k, priority := range solver.State.priorities() {
+		result.Levels[k].Priority = priority
+	}
+	for c, constraint := range solver.State.Constraints {
+		if !constraint.Soft {
+			continue
+		}
+		level := &result.Levels[s.soft.level[c]]
+		if constraint.ConstraintFunction(&solver.State.Vars) {
+			level.Satisfied++
+			continue
+		}
+		level.Violated++
+		level.Cost += constraint.weight()
+		result.Cost += constraint.weight()
+		result.Violated = append(result.Violated, constraint)
+	}
+	return result, nil
+}
+
+// penalize count the soft constraints on the variable at position i that its
+// assignment has broken. Returns their positions, for unpenalize.
+func (s *search[T]) penalize(i int) []int {
+	if s.soft == nil {
+		return nil
+	}
+	var penalized []int
+	for _, constraints := range [][]int{s.state.index.incident[i], s.state.index.global} {
+		for _, c := range constraints {
+			if s.state.Constraints[c].Soft && !s.soft.violated[c] && !s.state.satisfied(c) {
+				s.soft.add(c, s.state.Constraints[c].weight())
+				penalized = append(penalized, c)
+			}
+		}
+	}
+	return penalized
+}
+
+// unpenalize reverse penalize
+func (s *search[T]) unpenalize(penalized []int) {
+	for _, c := range penalized {
+		s.soft.remove(c, s.state.Constraints[c].weight())
+	}
+}
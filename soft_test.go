@@ -0,0 +1,132 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoftConstraints(t *testing.T) {
+	// three meetings, two rooms. A and B must be in different rooms, and
+	// we'd like C apart from both of them, but that isn't possible.
+	vars := Variables[int]{
+		NewVariable("A", IntRange(0, 2)),
+		NewVariable("B", IntRange(0, 2)),
+		NewVariable("C", IntRange(0, 2)),
+	}
+	constraints := Constraints[int]{
+		NotEquals[int]("A", "B"),
+		SoftConstraint(UnaryEquals[int]("A", 0), 2),
+		SoftConstraint(UnaryEquals[int]("B", 0), 1),
+	}
+	constraints = append(constraints, SoftConstraints(Constraints[int]{
+		NotEquals[int]("C", "A"),
+		NotEquals[int]("C", "B"),
+	}, 5)...)
+
+	// plain Solve ignores the soft constraints
+	solver := NewBackTrackingCSPSolver(vars.Copy(), constraints)
+	success, err := solver.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, success)
+
+	solver = NewBackTrackingCSPSolver(vars.Copy(), constraints)
+	result, err := solver.SolveMaxCSP(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, result.Solved)
+	assert.True(t, result.Optimal)
+	assert.Equal(t, 6.0, result.Cost)
+	assert.Len(t, result.Violated, 2)
+	assert.Equal(t, VariableNames{"B"}, result.Violated[0].Vars)
+	assert.Equal(t, 0, solver.State.Vars.Find("A").Value)
+	assert.Equal(t, 1, solver.State.Vars.Find("B").Value)
+
+	// hard constraints are still enforced
+	constraints = append(constraints, Equals[int]("A", "B"))
+	solver = NewBackTrackingCSPSolver(vars.Copy(), constraints)
+	result, err = solver.SolveMaxCSP(context.TODO())
+	assert.Nil(t, err)
+	assert.False(t, result.Solved)
+}
+
+func TestSoftConstraintsPreAssigned(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(0, 3)),
+		NewVariable("B", IntRange(0, 3)),
+	}
+	vars.SetValue("A", 2)
+	constraints := Constraints[int]{
+		SoftConstraint(UnaryEquals[int]("A", 0), 4),
+		SoftConstraint(LessThan[int]("B", "A"), 1),
+		SoftConstraint(UnaryEquals[int]("B", 2), 1),
+	}
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	result, err := solver.SolveMaxCSP(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, result.Optimal)
+	// A=0 is broken before the search starts, and B can only satisfy one of the others
+	assert.Equal(t, 5.0, result.Cost)
+	assert.Len(t, result.Violated, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	vars, constraints = queensProblem(8)
+	solver = NewBackTrackingCSPSolver(vars, SoftConstraints(constraints, 1))
+	_, err = solver.SolveMaxCSP(ctx)
+	assert.ErrorIs(t, err, ErrExecutionCanceled)
+}
+
+func TestConstraintHierarchy(t *testing.T) {
+	// a meeting slot for two people. Strongly prefer the morning, then
+	// keep Bob and Alice together, and only then worry about lunch.
+	vars := Variables[int]{
+		NewVariable("Alice", IntRange(8, 18)),
+		NewVariable("Bob", IntRange(8, 18)),
+	}
+	morning := func(name VariableName) Constraint[int] {
+		return NewIndexedConstraint(VariableNames{name}, func(variables *Variables[int], indices []int) bool {
+			variable := variables.At(indices[0])
+			return variable.Empty || variable.Value < 12
+		})
+	}
+	constraints := Constraints[int]{
+		// Bob can't do 8 or 9 o'clock
+		NewIndexedConstraint(VariableNames{"Bob"}, func(variables *Variables[int], indices []int) bool {
+			variable := variables.At(indices[0])
+			return variable.Empty || variable.Value >= 10
+		}),
+		PreferredConstraint(morning("Alice"), StrongPriority, 1),
+		PreferredConstraint(morning("Bob"), StrongPriority, 1),
+		PreferredConstraint(Equals[int]("Alice", "Bob"), MediumPriority, 1),
+		// a weak preference with a huge weight still loses to any higher level
+		PreferredConstraint(UnaryEquals[int]("Alice", 12), WeakPriority, 100),
+		PreferredConstraint(UnaryEquals[int]("Bob", 12), WeakPriority, 100),
+	}
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	result, err := solver.SolveMaxCSP(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, result.Optimal)
+	assert.Equal(t, 10, solver.State.Vars.Find("Alice").Value)
+	assert.Equal(t, 10, solver.State.Vars.Find("Bob").Value)
+	assert.Equal(t, 200.0, result.Cost)
+	assert.Equal(t, []LevelResult{
+		{Priority: StrongPriority, Satisfied: 2},
+		{Priority: MediumPriority, Satisfied: 1},
+		{Priority: WeakPriority, Cost: 200, Violated: 2},
+	}, result.Levels)
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"time"
+)
+
+// SolveStatus how a search ended
+type SolveStatus int
+
+const (
+	// StatusSolved a solution was found, or for RunArcConsistency, every
+	// domain still has a value
+	StatusSolved SolveStatus = iota
+	// StatusUnsat the problem was proven to have no solution
+	StatusUnsat
+	// StatusTimeout the context finished before the search did
+	StatusTimeout
+	// StatusLimit the search stopped at a limit, such as
+	// SolverOptions.MaxDiscrepancies, without proving there is no solution
+	StatusLimit
+)
+
+// String name of the status
+func (status SolveStatus) String() string {
+	switch status {
+	case StatusSolved:
+		return "Solved"
+	case StatusUnsat:
+		return "Unsat"
+	case StatusTimeout:
+		return "Timeout"
+	case StatusLimit:
+		return "Limit"
+	}
+	return "Unknown"
+}
+
+// SolveResult how a search went
+type SolveResult struct {
+	Status SolveStatus
+	// Nodes number of assignments made
+	Nodes int
+	// Fails number of assignments rejected by a constraint, nogood or bound
+	Fails int
+	// Backtracks number of times a variable was unassigned after its values ran out
+	Backtracks int
+	// Propagations number of assignments the Propagations were run for, or
+	// for RunArcConsistency, the number of arcs revised
+	Propagations int
+	// DomainRemovals number of values removed from domains by propagation
+	DomainRemovals int
+	// MaxDepth most variables assigned by the search at once
+	MaxDepth int
+	// Elapsed total time taken
+	Elapsed time.Duration
+	// PropagationTime time spent propagating
+	PropagationTime time.Duration
+	// SearchTime the rest of Elapsed
+	SearchTime time.Duration
+}
+
+// Run solve the CSP like Solve, reporting how the search went. Unlike
+// Solve, the search runs on the calling goroutine, checking ctx between
+// assignments, so a constraint function that never returns can't be
+// abandoned. If ctx finishes first, the Status is StatusTimeout and
+// ErrExecutionCanceled is returned.
+func (solver *BackTrackingCSPSolver[T]) Run(ctx context.Context) (SolveResult, error) {
+	start := time.Now()
+	s := newSearch(ctx, solver)
+	solved := s.run()
+	result := SolveResult{Nodes: s.nodes, Fails: s.fails, Backtracks: s.backtracks, Propagations: s.propagations,
+		DomainRemovals: s.removals, MaxDepth: s.maxDepth, PropagationTime: s.propagating}
+	result.elapsed(start)
+	switch {
+	case solved:
+		result.Status = StatusSolved
+	case s.aborted:
+		result.Status = StatusTimeout
+		return result, ErrExecutionCanceled
+	case s.limited:
+		result.Status = StatusLimit
+	default:
+		result.Status = StatusUnsat
+	}
+	return result, nil
+}
+
+// RunArcConsistency make the CSP arc consistent like MakeArcConsistent,
+// reporting how many arcs were revised and values removed. If a domain is
+// emptied, the Status is StatusUnsat rather than a panic. Like Run, it works
+// on the calling goroutine, checking ctx between arcs, so the state is left
+// alone once it returns. If ctx finishes first, the Status is StatusTimeout
+// and ErrExecutionCanceled is returned, with the domains partly pruned.
+func (state *CSPState[T]) RunArcConsistency(ctx context.Context) (SolveResult, error) {
+	start := time.Now()
+	result := SolveResult{}
+	c, err := state.arcConsistency(ctx.Done(), &result)
+	result.elapsed(start)
+	result.PropagationTime, result.SearchTime = result.Elapsed, 0
+	if err != nil {
+		result.Status = StatusTimeout
+		return result, err
+	}
+	if c >= 0 {
+		result.Status = StatusUnsat
+	}
+	return result, nil
+}
+
+// elapsed fill in the times taken since start
+func (result *SolveResult) elapsed(start time.Time) {
+	result.Elapsed = time.Since(start)
+	result.SearchTime = result.Elapsed - result.PropagationTime
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Gabriel Boorse
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package centipede
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunStatistics(t *testing.T) {
+	vars, constraints := queensProblem(8)
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	result, err := solver.Run(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, StatusSolved, result.Status)
+	assert.Equal(t, "Solved", result.Status.String())
+	assert.True(t, solver.State.Vars.Complete())
+	assert.Equal(t, 8, result.MaxDepth)
+	assert.Greater(t, result.Backtracks, 0)
+	assert.Greater(t, result.Fails, result.Backtracks)
+	// every node either failed, was backtracked out of, or is in the solution
+	assert.Equal(t, result.Nodes, result.Fails+result.Backtracks+8)
+	assert.Zero(t, result.Propagations)
+	// with nothing to propagate, all the time is spent searching
+	assert.Zero(t, result.PropagationTime)
+	assert.Greater(t, result.SearchTime, time.Duration(0))
+
+	// Run finds the same solution as Solve
+	vars, constraints = queensProblem(8)
+	other := NewBackTrackingCSPSolver(vars, constraints)
+	solved, err := other.Solve(context.TODO())
+	assert.Nil(t, err)
+	assert.True(t, solved)
+	assert.Equal(t, other.State.Vars, solver.State.Vars)
+}
+
+func TestRunPropagationStatistics(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("A", IntRange(0, 3)),
+		NewVariable("B", IntRange(0, 3)),
+		NewVariable("C", IntRange(0, 3)),
+	}
+	// whatever value one variable takes, the others can't
+	propagations := Propagations[int]{{Vars: VariableNames{"A", "B", "C"}, PropagationFunction: func(assignment VariableAssignment[int], variables *Variables[int]) []DomainRemoval[int] {
+		removals := make([]DomainRemoval[int], 0)
+		for _, variable := range *variables {
+			if variable.Name != assignment.VariableName {
+				removals = append(removals, DomainRemoval[int]{VariableName: variable.Name, Value: assignment.Value})
+			}
+		}
+		return removals
+	}}}
+	solver := NewBackTrackingCSPSolverWithPropagation(vars, AllUnique[int]("A", "B", "C"), propagations)
+	result, err := solver.Run(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, StatusSolved, result.Status)
+	assert.Equal(t, 3, result.Nodes)
+	assert.Equal(t, 3, result.Propagations)
+	// 0 from B and C, then 1 from C
+	assert.Equal(t, 3, result.DomainRemovals)
+	assert.Zero(t, result.Fails)
+	assert.Greater(t, result.PropagationTime, time.Duration(0))
+}
+
+func TestRunStatus(t *testing.T) {
+	evaluations := 0
+	vars, constraints := pigeonholeProblem(&evaluations)
+	solver := NewBackTrackingCSPSolver(vars, constraints)
+	result, err := solver.Run(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, StatusUnsat, result.Status)
+	assert.Greater(t, result.Fails, 0)
+
+	// limited discrepancy search gives up without proving anything
+	vars, constraints = queensProblem(8)
+	solver = NewBackTrackingCSPSolver(vars, constraints)
+	solver.Options.Search = LimitedDiscrepancySearch
+	solver.Options.MaxDiscrepancies = 1
+	result, err = solver.Run(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, StatusLimit, result.Status)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	vars, constraints = queensProblem(8)
+	solver = NewBackTrackingCSPSolver(vars, constraints)
+	result, err = solver.Run(ctx)
+	assert.ErrorIs(t, err, ErrExecutionCanceled)
+	assert.Equal(t, StatusTimeout, result.Status)
+}
+
+func TestRunArcConsistency(t *testing.T) {
+	vars := Variables[int]{
+		NewVariable("X", IntRange(0, 3)),
+		NewVariable("Y", IntRange(0, 3)),
+		NewVariable("Z", IntRange(0, 3)),
+	}
+	state := CSPState[int]{Vars: vars, Constraints: Constraints[int]{LessThan[int]("X", "Y"), LessThan[int]("Y", "Z")}}
+	result, err := state.RunArcConsistency(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, StatusSolved, result.Status)
+	// X, Y and Z are each left with one of their three values
+	assert.Equal(t, 6, result.DomainRemovals)
+	assert.Greater(t, result.Propagations, 0)
+	assert.Greater(t, result.PropagationTime, time.Duration(0))
+	assert.Zero(t, result.Nodes)
+
+	// an empty domain is reported rather than panicking
+	vars = Variables[int]{
+		NewVariable("A", IntRange(0, 3)),
+		NewVariable("B", IntRange(0, 3)),
+	}
+	state = CSPState[int]{Vars: vars, Constraints: Constraints[int]{LessThan[int]("A", "B"), GreaterThan[int]("A", "B")}}
+	result, err = state.RunArcConsistency(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, StatusUnsat, result.Status)
+
+	// cancelled before the first arc, so nothing is pruned
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	vars = Variables[int]{
+		NewVariable("A", IntRange(0, 3)),
+		NewVariable("B", IntRange(0, 3)),
+	}
+	state = CSPState[int]{Vars: vars, Constraints: Constraints[int]{LessThan[int]("A", "B")}}
+	result, err = state.RunArcConsistency(ctx)
+	assert.ErrorIs(t, err, ErrExecutionCanceled)
+	assert.Equal(t, StatusTimeout, result.Status)
+	assert.Zero(t, result.Propagations)
+	assert.Equal(t, IntRange(0, 3), state.Vars.Find("A").Domain)
+}
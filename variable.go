@@ -64,76 +64,81 @@ func (variable *Variable[T]) SetDomain(domain Domain[T]) {
 // Variables collection type for interface{} type variables
 type Variables[T comparable] []Variable[T]
 
-// SetValue setter for Variables collection
-func (variables *Variables[T]) SetValue(name VariableName, value T) {
-	foundIndex := -1
+// VariableIndex map from variable name to its position in a Variables collection
+type VariableIndex map[VariableName]int
+
+// Index build a VariableIndex for this collection
+func (variables *Variables[T]) Index() VariableIndex {
+	index := make(VariableIndex, len(*variables))
+	for i, variable := range *variables {
+		index[variable.Name] = i
+	}
+	return index
+}
 
-	for index, variable := range *variables {
-		if variable.Name == name {
-			foundIndex = index
+// IndexOf position of the variable with the given name, or -1 if not found
+func (variables *Variables[T]) IndexOf(name VariableName) int {
+	for i := range *variables {
+		if (*variables)[i].Name == name {
+			return i
 		}
 	}
-	if !(foundIndex >= 0) {
-		panic(fmt.Sprintf("Variable not found by name %v in variables %v", name, variables))
-	} else {
-		(*variables)[foundIndex].Value = value
-		(*variables)[foundIndex].Empty = false
+	return -1
+}
 
-	}
+// At get the Variable at the given position in the collection
+func (variables *Variables[T]) At(index int) *Variable[T] {
+	return &(*variables)[index]
+}
+
+// SetValue setter for Variables collection
+func (variables *Variables[T]) SetValue(name VariableName, value T) {
+	variables.Find(name).SetValue(value)
 }
 
 // Unset unset a variable with the given name
 func (variables *Variables[T]) Unset(name VariableName) {
-	foundIndex := -1
-
-	for index, variable := range *variables {
-		if variable.Name == name {
-			foundIndex = index
-		}
-	}
-	if !(foundIndex >= 0) {
-		panic(fmt.Sprintf("Variable not found by name %v in variables %v", name, variables))
-	} else {
-		(*variables)[foundIndex].Empty = true
-
-	}
+	variables.Find(name).Unset()
 }
 
 // SetDomain set the domain of the given variable by name
 func (variables *Variables[T]) SetDomain(name VariableName, domain Domain[T]) {
-	foundIndex := -1
+	variables.Find(name).SetDomain(domain)
+}
 
-	for index, variable := range *variables {
-		if variable.Name == name {
-			foundIndex = index
-		}
-	}
-	if !(foundIndex >= 0) {
+// Find find an Variable by name in an Variables collection
+func (variables *Variables[T]) Find(name VariableName) *Variable[T] {
+	index := variables.IndexOf(name)
+	if index < 0 {
 		panic(fmt.Sprintf("Variable not found by name %v in variables %v", name, variables))
-	} else {
-		(*variables)[foundIndex].Domain = domain
-
 	}
+	return variables.At(index)
 }
 
-// Find find an Variable by name in an Variables collection
-func (variables *Variables[T]) Find(name VariableName) *Variable[T] {
-	for i := 0; i < len(*variables); i++ {
-		if (*variables)[i].Name == name {
-			return &(*variables)[i]
+// at whether the variables at the given positions have the given names
+func (variables *Variables[T]) at(names VariableNames, indices []int) bool {
+	for i, name := range names {
+		if indices[i] >= len(*variables) || (*variables)[indices[i]].Name != name {
+			return false
 		}
 	}
-	panic(fmt.Sprintf("Variable not found by name %v in variables %v", name, variables))
+	return true
 }
 
 // Contains slice contains method for Variables
 func (variables *Variables[T]) Contains(name VariableName) bool {
-	for _, variable := range *variables {
-		if variable.Name == name {
-			return true
-		}
+	return variables.IndexOf(name) >= 0
+}
+
+// Copy return a copy of the collection whose domains can be changed
+// without affecting the original
+func (variables *Variables[T]) Copy() Variables[T] {
+	copied := make(Variables[T], len(*variables))
+	for i, variable := range *variables {
+		copied[i] = variable
+		copied[i].Domain = append(Domain[T]{}, variable.Domain...)
 	}
-	return false
+	return copied
 }
 
 // Unassigned return the number of unassigned variables
@@ -152,28 +157,39 @@ func (variables *Variables[T]) Complete() bool {
 	return variables.Unassigned() == 0
 }
 
-// EvaluateDomainRemovals remove values from domain based on DomainRemovals in propagation
-func (variables *Variables[T]) EvaluateDomainRemovals(domainRemovals DomainRemovals[T]) {
+// EvaluateDomainRemovals remove values from domain based on DomainRemovals in propagation.
+// Returns the removals that actually changed a domain, which are the ones
+// that should later be passed to ResetDomainRemovalEvaluation.
+func (variables *Variables[T]) EvaluateDomainRemovals(domainRemovals DomainRemovals[T]) DomainRemovals[T] {
+	return evaluateDomainRemovals(domainRemovals, variables.Find)
+}
+
+// ResetDomainRemovalEvaluation undo pruning on a variable's domain
+func (variables *Variables[T]) ResetDomainRemovalEvaluation(domainRemovals DomainRemovals[T]) {
+	resetDomainRemovalEvaluation(domainRemovals, variables.Find)
+}
+
+// evaluateDomainRemovals EvaluateDomainRemovals, looking variables up with find
+func evaluateDomainRemovals[T comparable](domainRemovals DomainRemovals[T], find func(VariableName) *Variable[T]) DomainRemovals[T] {
+	applied := make(DomainRemovals[T], 0, len(domainRemovals))
 	for _, removal := range domainRemovals {
 		// prune values from domain
-		modifiedVariable := variables.Find(removal.VariableName)
-		if modifiedVariable.Empty {
+		modifiedVariable := find(removal.VariableName)
+		if modifiedVariable.Empty && modifiedVariable.Domain.Contains(removal.Value) {
 			modifiedVariable.Domain = modifiedVariable.Domain.Remove(removal.Value)
-			// fmt.Printf("Removed value %v from domain for variable %v. New Domain is: %v\n",
-			// 	removal.Value, removal.VariableName, variables.Find(removal.VariableName).Domain)
+			applied = append(applied, removal)
 		}
 	}
+	return applied
 }
 
-// ResetDomainRemovalEvaluation undo pruning on a variable's domain
-func (variables *Variables[T]) ResetDomainRemovalEvaluation(domainRemovals DomainRemovals[T]) {
+// resetDomainRemovalEvaluation ResetDomainRemovalEvaluation, looking variables up with find
+func resetDomainRemovalEvaluation[T comparable](domainRemovals DomainRemovals[T], find func(VariableName) *Variable[T]) {
 	for _, removal := range domainRemovals {
 		// add back all pruned domain values
-		modifiedVariable := variables.Find(removal.VariableName)
+		modifiedVariable := find(removal.VariableName)
 		if !modifiedVariable.Domain.Contains(removal.Value) {
 			modifiedVariable.Domain = append(modifiedVariable.Domain, removal.Value)
-			// fmt.Printf("Added value %v to domain for variable %v. New Domain is: %v\n",
-			// 	removal.Value, removal.VariableName, variables.Find(removal.VariableName).Domain)
 		}
 	}
 }